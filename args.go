@@ -0,0 +1,203 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var errUnbalancedQuotes = errors.New("unbalanced quotes in request")
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func hexDigitToInt(c byte) byte {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0'
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+// unescapeAt decodes the backslash escape starting at s[i] (s[i] == '\\').
+// It returns the decoded byte and the number of bytes consumed.
+// Supported escapes are \xHH, \n, \r, \t, \b and \a. Any other escaped
+// character is taken literally, so that \\ and \" work as expected.
+func unescapeAt(s string, i int) (byte, int) {
+	if i+1 >= len(s) {
+		return '\\', 1
+	}
+	if s[i+1] == 'x' && i+3 < len(s) && isHexDigit(s[i+2]) && isHexDigit(s[i+3]) {
+		return hexDigitToInt(s[i+2])<<4 | hexDigitToInt(s[i+3]), 4
+	}
+	switch c := s[i+1]; c {
+	case 'n':
+		return '\n', 2
+	case 'r':
+		return '\r', 2
+	case 't':
+		return '\t', 2
+	case 'b':
+		return '\b', 2
+	case 'a':
+		return '\a', 2
+	default:
+		return c, 2
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\v' || c == '\f'
+}
+
+// splitArgs splits a line into arguments the same way redis-cli does.
+// Arguments are separated by whitespace. Double quoted arguments support
+// backslash escapes (see unescapeAt), single quoted arguments only support \'.
+// A closing quote must be followed by whitespace or the end of the line.
+func splitArgs(line string) ([]string, error) {
+	args, _, err := splitArgsWithStarts(line)
+	return args, err
+}
+
+// splitArgsWithStarts is like splitArgs, and also returns the offset where each argument starts in the line.
+func splitArgsWithStarts(line string) ([]string, []int, error) {
+	args := []string{}
+	starts := []int{}
+	i := 0
+	for {
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+		if i >= len(line) {
+			return args, starts, nil
+		}
+		starts = append(starts, i)
+		var cur strings.Builder
+		inDQ, inSQ, done := false, false, false
+		for !done {
+			if inDQ {
+				if i >= len(line) {
+					return nil, nil, errUnbalancedQuotes
+				}
+				switch line[i] {
+				case '\\':
+					c, n := unescapeAt(line, i)
+					cur.WriteByte(c)
+					i += n - 1
+				case '"':
+					// closing quote must be followed by a space or nothing at all
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return nil, nil, errUnbalancedQuotes
+					}
+					done = true
+				default:
+					cur.WriteByte(line[i])
+				}
+			} else if inSQ {
+				if i >= len(line) {
+					return nil, nil, errUnbalancedQuotes
+				}
+				switch {
+				case line[i] == '\\' && i+1 < len(line) && line[i+1] == '\'':
+					cur.WriteByte('\'')
+					i++
+				case line[i] == '\'':
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return nil, nil, errUnbalancedQuotes
+					}
+					done = true
+				default:
+					cur.WriteByte(line[i])
+				}
+			} else {
+				if i >= len(line) {
+					break
+				}
+				switch c := line[i]; {
+				case isSpace(c):
+					done = true
+				case c == '"':
+					inDQ = true
+				case c == '\'':
+					inSQ = true
+				default:
+					cur.WriteByte(c)
+				}
+			}
+			if i < len(line) {
+				i++
+			}
+		}
+		args = append(args, cur.String())
+	}
+}
+
+const (
+	// optFlag is an option without value, like -values
+	optFlag = iota
+	// optValue is an option with a value, like -depth 3
+	optValue
+	// optData is an option whose value is a key or a value in the database,
+	// so it is decoded like the other arguments.
+	optData
+)
+
+// optSpec maps the name of an option to its kind
+type optSpec map[string]int
+
+// cmdOptions holds the options accepted by commands.
+// Options can appear anywhere after the command name, and "--" ends the options.
+var cmdOptions = map[string]optSpec{
+	"tree": {
+		"-depth":  optValue,
+		"-values": optFlag,
+	},
+}
+
+// parseOptions separates the options of given command from the other arguments.
+// The value of an option without value is "".
+func parseOptions(cmd string, args []string) (map[string]string, []string, error) {
+	spec := cmdOptions[cmd]
+	opts := map[string]string{}
+	rest := []string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			rest = append(rest, args[i+1:]...)
+			break
+		}
+		kind, ok := spec[arg]
+		if !ok {
+			rest = append(rest, arg)
+			continue
+		}
+		if kind == optFlag {
+			opts[arg] = ""
+			continue
+		}
+		if i+1 >= len(args) {
+			return nil, nil, fmt.Errorf("option '%s' requires a value", arg)
+		}
+		i++
+		opts[arg] = args[i]
+	}
+	return opts, rest, nil
+}
+
+// intOption returns the value of an integer option, or def if the option is not given.
+func intOption(opts map[string]string, name string, def int) (int, error) {
+	s, ok := opts[name]
+	if !ok {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("value of option '%s' should be a non-negative integer", name)
+	}
+	return n, nil
+}
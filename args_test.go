@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitArgs(t *testing.T) {
+	args, err := splitArgs("  set  bucket key value ")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"set", "bucket", "key", "value"}, args)
+
+	args, err = splitArgs(`set bucket "key with space" 'it\'s' ""`)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"set", "bucket", "key with space", "it's", ""}, args)
+
+	args, err = splitArgs(`get "a\tb\nc\x00\xff\"\\"`)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"get", "a\tb\nc\x00\xff\"\\"}, args)
+
+	// escapes are not handled outside double quotes
+	args, err = splitArgs(`get a\nb 'c\n'`)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"get", `a\nb`, `c\n`}, args)
+
+	args, err = splitArgs("")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{}, args)
+
+	for _, line := range []string{`get "bucket`, `get 'bucket`, `get "bucket"key`, `get 'bucket'key`, `get "bucket\`} {
+		_, err = splitArgs(line)
+		assert.Equal(t, errUnbalancedQuotes, err, line)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func init() {
+	cmdOptions["backup"] = optSpec{
+		"-gzip":     optFlag,
+		"-checksum": optFlag,
+	}
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// writeBackup writes a snapshot of the database in the transaction to w,
+// and returns the number of bytes written to w.
+func writeBackup(tx *bolt.Tx, w io.Writer, compress bool) (int64, error) {
+	cw := &countingWriter{w: w}
+	if !compress {
+		_, err := tx.WriteTo(cw)
+		return cw.n, err
+	}
+	zw := gzip.NewWriter(cw)
+	_, err := tx.WriteTo(zw)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	return cw.n, err
+}
+
+// sameFile reports whether the two paths are the same file, or would be if they existed.
+func sameFile(a, b string) bool {
+	if fa, err := os.Stat(a); err == nil {
+		if fb, err := os.Stat(b); err == nil {
+			return os.SameFile(fa, fb)
+		}
+	}
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+func backup(args ...string) (res interface{}, err error) {
+	opts, args, err := parseOptions("backup", args)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) != 1 {
+		return nil, fmt.Errorf("wrong number of arguments for '%s' command", "backup")
+	}
+	_, compress := opts["-gzip"]
+	_, withChecksum := opts["-checksum"]
+	path := args[0]
+	if curTx != nil {
+		// a snapshot taken in the transaction would miss the writes which are not committed yet
+		return nil, errors.New("can't back up the database in a transaction")
+	}
+	if sameFile(path, DbPath) {
+		return nil, errors.New("can't back up the database to itself")
+	}
+	if _, err = os.Stat(path); err == nil {
+		return nil, fmt.Errorf("%s already exists", path)
+	}
+	checksumPath := path + ".sha256"
+	if withChecksum {
+		if _, err = os.Stat(checksumPath); err == nil {
+			return nil, fmt.Errorf("%s already exists", checksumPath)
+		}
+	}
+
+	// write to a temporary file first, so that a failed backup leaves nothing behind
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return nil, err
+	}
+	tmpPath := f.Name()
+	var w io.Writer = f
+	var h hash.Hash
+	if withChecksum {
+		h = sha256.New()
+		w = io.MultiWriter(f, h)
+	}
+	var size int64
+	// a read-only transaction gives a consistent snapshot without blocking the others
+	err = view(func(tx *bolt.Tx) error {
+		size, err = writeBackup(tx, w, compress)
+		return err
+	})
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+
+	info := map[string]interface{}{
+		"size": size,
+	}
+	if withChecksum {
+		sum := hex.EncodeToString(h.Sum(nil))
+		// the same format as sha256sum, so that the backup can be verified with `sha256sum -c`
+		line := fmt.Sprintf("%s  %s\n", sum, filepath.Base(path))
+		if err = ioutil.WriteFile(checksumPath, []byte(line), 0644); err != nil {
+			return nil, err
+		}
+		info["sha256"] = sum
+	}
+	return info, nil
+}
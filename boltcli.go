@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 
+	"github.com/chzyer/readline"
 	bolt "go.etcd.io/bbolt"
 )
 
@@ -18,11 +20,36 @@ var (
 	shouldPrintVersion = flag.Bool("version", false, "Output version and exit.")
 	version            = "1.0.0"
 
-	scriptPath = flag.String("e", "", "Eval the Lua script in given path")
+	scriptPath   = flag.String("e", "", "Eval the Lua script in given path")
+	readOnly     = flag.Bool("readonly", false, "Open the database in read-only mode, so that it can be shared with other readers")
+	openTimeout  = flag.Duration("timeout", 0, "Timeout to wait for the file lock of the database, 0 means waiting indefinitely")
+	encodingFlag = flag.String("encoding", encodingRaw, "Encoding of keys and values in the command line: raw, hex, base64 or escaped")
+	dumpPath     = flag.String("dump", "", "Dump the database to the json file in given path and exit")
+	restorePath  = flag.String("restore", "", "Restore the database from the json file in given path and exit")
+	outputFlag   = flag.String("output", outputText, "Output format of the command line: text, json or ndjson")
+	sepFlag      = flag.String("separator", PathSeparator, "Separator of the bucket names in a path like /bucket/subbucket:key")
+	compactPath  = flag.String("compact", "", "Compact the database into a new file in given path and exit")
+	txSizeFlag   = flag.Int("txsize", defaultCompactTxSize, "Max size of the transactions used by -compact, 0 means no limit")
+	swapFlag     = flag.Bool("swap", false, "Replace the database with the compacted file after -compact")
+	backupPath   = flag.String("backup", "", "Write a snapshot of the database to given path and exit")
+	gzipFlag     = flag.Bool("gzip", false, "Compress the snapshot written by -backup with gzip")
+	checksumFlag = flag.Bool("checksum", false, "Write the sha256 of the snapshot written by -backup to path.sha256")
+	configPath   = flag.String("config", "", "Path of the config file in json, default to ~/.config/boltcli.json")
 )
 
+func openDB(dbPath string) (*bolt.DB, error) {
+	options := *bolt.DefaultOptions
+	options.ReadOnly = *readOnly
+	options.Timeout = *openTimeout
+	db, err := bolt.Open(dbPath, 0600, &options)
+	if err == bolt.ErrTimeout {
+		err = fmt.Errorf("timeout after %v, the file is locked by another process", *openTimeout)
+	}
+	return db, err
+}
+
 func initDB(dbPath string) {
-	db, err := bolt.Open(dbPath, 0600, nil)
+	db, err := openDB(dbPath)
 	if err != nil {
 		log.Fatalf("Could not open %s: %v", dbPath, err)
 	}
@@ -43,14 +70,58 @@ func main() {
 	if flag.NArg() < 1 {
 		log.Fatalf("database filename is required.")
 	}
+	if err := setEncoding(*encodingFlag); err != nil {
+		log.Fatalln(err)
+	}
+	if err := setOutputFormat(*outputFlag); err != nil {
+		log.Fatalln(err)
+	}
+	if err := setPathSeparator(*sepFlag); err != nil {
+		log.Fatalln(err)
+	}
+	path, required := *configPath, true
+	if path == "" {
+		path, required = defaultConfigPath(), false
+	}
+	if err := loadConfig(path, required); err != nil {
+		log.Fatalln(err)
+	}
 	initDB(flag.Arg(0))
-	defer DB.Close()
+	ok := true
 	if *scriptPath != "" {
 		err := StartScript(*scriptPath)
 		if err != nil {
+			DB.Close()
 			log.Fatalln(err)
 		}
+	} else if *dumpPath != "" {
+		ok = RunCmd(os.Stdout, []string{"dump", *dumpPath})
+	} else if *restorePath != "" {
+		ok = RunCmd(os.Stdout, []string{"restore", *restorePath})
+	} else if *compactPath != "" {
+		args := []string{"compact", *compactPath, "-txsize", strconv.Itoa(*txSizeFlag)}
+		if *swapFlag {
+			args = append(args, "-swap")
+		}
+		ok = RunCmd(os.Stdout, args)
+	} else if *backupPath != "" {
+		args := []string{"backup", *backupPath}
+		if *gzipFlag {
+			args = append(args, "-gzip")
+		}
+		if *checksumFlag {
+			args = append(args, "-checksum")
+		}
+		ok = RunCmd(os.Stdout, args)
+	} else if flag.NArg() > 1 {
+		ok = RunCmd(os.Stdout, flag.Args()[1:])
+	} else if !readline.IsTerminal(int(os.Stdin.Fd())) {
+		ok = StartBatch(os.Stdin, os.Stdout)
 	} else {
 		StartCli()
 	}
+	DB.Close()
+	if !ok {
+		os.Exit(1)
+	}
 }
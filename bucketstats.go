@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	bucketStatsByName = "name"
+	bucketStatsBySize = "size"
+	bucketStatsByKeys = "keys"
+)
+
+// bucketStatsOrders are the orders supported by the -sort option of bucketstats
+var bucketStatsOrders = []string{bucketStatsByName, bucketStatsBySize, bucketStatsByKeys}
+
+func init() {
+	cmdOptions["bucketstats"] = optSpec{
+		"-recursive": optFlag,
+		"-sort":      optValue,
+		"-limit":     optValue,
+	}
+}
+
+type bucketStat struct {
+	path  string
+	stats bolt.BucketStats
+}
+
+// inuse returns the bytes used by the bucket. The inline buckets nested in it are already counted
+// in LeafInuse, while an inline bucket itself has no pages and only counts in InlineBucketInuse.
+func (s *bucketStat) inuse() int {
+	if s.stats.BranchPageN == 0 && s.stats.LeafPageN == 0 {
+		return s.stats.InlineBucketInuse
+	}
+	return s.stats.BranchInuse + s.stats.LeafInuse
+}
+
+func (s *bucketStat) result() []interface{} {
+	info := intFields(s.stats)
+	info["Inuse"] = int64(s.inuse())
+	info["Alloc"] = int64(s.stats.BranchAlloc + s.stats.LeafAlloc)
+	return []interface{}{s.path, info}
+}
+
+func parseBucketStatsOrder(opts map[string]string) (string, error) {
+	order, ok := opts["-sort"]
+	if !ok {
+		return bucketStatsByName, nil
+	}
+	order = strings.ToLower(order)
+	for _, o := range bucketStatsOrders {
+		if o == order {
+			return order, nil
+		}
+	}
+	return "", fmt.Errorf("unknown order '%s', available orders: %s", order, strings.Join(bucketStatsOrders, ", "))
+}
+
+func bucketstats(args ...string) (res interface{}, err error) {
+	opts, args, err := parseOptions("bucketstats", args)
+	if err != nil {
+		return nil, err
+	}
+	_, recursive := opts["-recursive"]
+	limit, err := intOption(opts, "-limit", 0)
+	if err != nil {
+		return nil, err
+	}
+	order, err := parseBucketStatsOrder(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	collected := []*bucketStat{}
+	var collect func(path []string, b *bolt.Bucket)
+	collect = func(path []string, b *bolt.Bucket) {
+		collected = append(collected, &bucketStat{formatPath(path), b.Stats()})
+		if !recursive {
+			return
+		}
+		b.ForEach(func(k, v []byte) error {
+			if sub := b.Bucket(k); sub != nil {
+				collect(append(append([]string{}, path...), string(k)), sub)
+			}
+			return nil
+		})
+	}
+	err = view(func(tx *bolt.Tx) error {
+		if len(args) == 0 {
+			return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+				collect([]string{string(name)}, b)
+				return nil
+			})
+		}
+		if b := findBucket(tx, args); b != nil {
+			collect(args, b)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the buckets are collected in the order of their names
+	switch order {
+	case bucketStatsBySize:
+		sort.SliceStable(collected, func(i, j int) bool {
+			return collected[i].inuse() > collected[j].inuse()
+		})
+	case bucketStatsByKeys:
+		sort.SliceStable(collected, func(i, j int) bool {
+			return collected[i].stats.KeyN > collected[j].stats.KeyN
+		})
+	}
+	if limit > 0 && len(collected) > limit {
+		collected = collected[:limit]
+	}
+	results := make([]interface{}, len(collected))
+	for i, s := range collected {
+		results[i] = s.result()
+	}
+	return results, nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// checker collects the problems found by check. The problems are written to
+// progressOutput as soon as they are found if the output format is text.
+type checker struct {
+	dumpCounter
+	problems []string
+	count    int64
+}
+
+func (c *checker) report(format string, args ...interface{}) {
+	c.count++
+	problem := fmt.Sprintf(format, args...)
+	if progressOutput != nil && OutputFormat == outputText {
+		fmt.Fprintln(progressOutput, problem)
+		return
+	}
+	c.problems = append(c.problems, problem)
+}
+
+// checkBucket validates the invariants which bolt doesn't check:
+// keys are not empty and sorted, and entries with nil values are buckets.
+func (c *checker) checkBucket(path []string, b *bolt.Bucket) {
+	c.buckets++
+	where := formatPath(path)
+	var prev []byte
+	cur := b.Cursor()
+	for k, v := cur.First(); k != nil; k, v = cur.Next() {
+		key := encodeOutput(string(k))
+		if len(k) == 0 {
+			c.report("%s: empty key", where)
+		} else if len(k) > bolt.MaxKeySize {
+			c.report("%s: key '%s' is longer than %d bytes", where, key, bolt.MaxKeySize)
+		}
+		if prev != nil && bytes.Compare(prev, k) >= 0 {
+			c.report("%s: key '%s' is out of order", where, key)
+		}
+		prev = append(prev[:0], k...)
+
+		if v == nil {
+			sub := b.Bucket(k)
+			if sub == nil {
+				c.report("%s: key '%s' has a nil value but is not a bucket", where, key)
+				continue
+			}
+			c.checkBucket(append(append([]string{}, path...), string(k)), sub)
+			continue
+		}
+		c.keys++
+		if len(v) > bolt.MaxValueSize {
+			c.report("%s: value of key '%s' is longer than %d bytes", where, key, bolt.MaxValueSize)
+		}
+	}
+}
+
+func check(args ...string) (res interface{}, err error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("wrong number of arguments for '%s' command", "check")
+	}
+	c := &checker{}
+	err = view(func(tx *bolt.Tx) error {
+		// the channel must be drained, otherwise the goroutine checking the pages is leaked
+		for err := range tx.Check() {
+			c.report("%v", err)
+		}
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			c.checkBucket([]string{string(name)}, b)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if c.count > 0 {
+		msg := fmt.Sprintf("found %d problems in %d buckets and %d keys", c.count, c.buckets, c.keys)
+		if len(c.problems) > 0 {
+			msg += ":\n" + strings.Join(c.problems, "\n")
+		}
+		return nil, errors.New(msg)
+	}
+	res = c.result()
+	res.(map[string]interface{})["problems"] = c.count
+	return res, nil
+}
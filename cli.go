@@ -1,6 +1,8 @@
 package main
 
 import (
+	"bufio"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -10,13 +12,9 @@ import (
 	"github.com/chzyer/readline"
 )
 
-func buildCompleter() readline.AutoCompleter {
-	cmds := []readline.PrefixCompleterInterface{}
-	for k := range CmdMap {
-		cmds = append(cmds, readline.PcItem(k))
-	}
-	return readline.NewPrefixCompleter(cmds...)
-}
+// progressOutput is where commands report what they find before the result is ready,
+// like the problems found by check. It is nil when the commands are not run from the command line.
+var progressOutput io.Writer
 
 func getHomeDir() string {
 	env := "HOME"
@@ -26,6 +24,13 @@ func getHomeDir() string {
 	return os.Getenv(env)
 }
 
+func prompt() string {
+	if len(CurBucket) == 0 {
+		return DbPath + "> "
+	}
+	return DbPath + ":" + formatPath(CurBucket) + "> "
+}
+
 // StartCli starts the repl environment
 func StartCli() {
 	historyFileDir := filepath.Join(getHomeDir(), ".cache")
@@ -35,7 +40,7 @@ func StartCli() {
 	}
 	l, err := readline.NewEx(&readline.Config{
 		AutoComplete:    buildCompleter(),
-		Prompt:          DbPath + "> ",
+		Prompt:          prompt(),
 		HistoryFile:     filepath.Join(historyFileDir, "boltclihistory"),
 		HistoryLimit:    1000,
 		InterruptPrompt: "^C",
@@ -46,6 +51,7 @@ func StartCli() {
 	}
 	defer l.Close()
 
+	progressOutput = os.Stdout
 	for {
 		line, err := l.Readline()
 		if err == readline.ErrInterrupt {
@@ -58,16 +64,68 @@ func StartCli() {
 			break
 		}
 
-		fields := strings.Fields(strings.TrimSpace(line))
-		if len(fields) == 0 {
-			continue
+		result, _ := runLine(line)
+		if result != "" {
+			fmt.Println(result)
+		} else if len(strings.TrimSpace(line)) != 0 {
+			fmt.Println("(empty list or set)")
 		}
+		// the current bucket may be changed
+		l.SetPrompt(prompt())
+	}
+}
 
-		result := ExecCmdInCli(fields[0], fields[1:]...)
+// runLine splits the line into a command and its arguments, and runs it.
+// It returns the formatted result and whether the command succeeded.
+// An empty line is ignored.
+func runLine(line string) (string, bool) {
+	fields, err := splitArgs(line)
+	if err != nil {
+		if OutputFormat != outputText {
+			return formatJSON(nil, err), false
+		}
+		return "ERR " + err.Error(), false
+	}
+	if len(fields) == 0 {
+		return "", true
+	}
+	return runCmdInCli(fields[0], fields[1:]...)
+}
+
+// RunCmd runs a single command given in the command line arguments, and writes the result to w.
+// It returns false if the command failed.
+func RunCmd(w io.Writer, args []string) bool {
+	progressOutput = w
+	result, ok := runCmdInCli(args[0], args[1:]...)
+	if result != "" {
+		fmt.Fprintln(w, result)
+	}
+	return ok
+}
+
+// StartBatch runs the commands read from r line by line, and writes their results to w.
+// Empty lines and lines starting with '#' are skipped.
+// It returns false if any of the commands failed.
+func StartBatch(r io.Reader, w io.Writer) bool {
+	progressOutput = w
+	allOK := true
+	scanner := bufio.NewScanner(r)
+	// allow long lines, for example, setting a large value
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		result, ok := runLine(line)
 		if result != "" {
-			println(result)
-		} else {
-			println("(empty list or set)")
+			fmt.Fprintln(w, result)
 		}
+		allOK = allOK && ok
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(w, "ERR failed to read commands: %v\n", err)
+		return false
 	}
+	return allOK
 }
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *CmdSuite) TestRunCmd() {
+	var out bytes.Buffer
+	assert.True(suite.T(), RunCmd(&out, []string{"set", "bucket", "key", "value with space"}))
+	assert.True(suite.T(), RunCmd(&out, []string{"get", "bucket", "key"}))
+	assert.True(suite.T(), RunCmd(&out, []string{"keys", "bucket", "non-exist"}))
+	assert.False(suite.T(), RunCmd(&out, []string{"get", "bucket"}))
+	assert.Equal(suite.T(),
+		"true\n\"value with space\"\nERR wrong number of arguments for 'get' command\n", out.String())
+}
+
+func (suite *CmdSuite) TestStartBatch() {
+	var out bytes.Buffer
+	input := strings.Join([]string{
+		"# comments are skipped",
+		`set bucket key "value with space"`,
+		"",
+		"get bucket key",
+	}, "\n")
+	assert.True(suite.T(), StartBatch(strings.NewReader(input), &out))
+	assert.Equal(suite.T(), "true\n\"value with space\"\n", out.String())
+
+	out.Reset()
+	input = "get bucket\nget \"bucket\nexists bucket"
+	assert.False(suite.T(), StartBatch(strings.NewReader(input), &out))
+	assert.Equal(suite.T(),
+		"ERR wrong number of arguments for 'get' command\nERR unbalanced quotes in request\ntrue\n", out.String())
+}
@@ -7,6 +7,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	bolt "go.etcd.io/bbolt"
 	"github.com/gobwas/glob"
@@ -18,7 +19,7 @@ func del(args ...string) (res interface{}, err error) {
 		return nil, fmt.Errorf("wrong number of arguments for '%s' command", "del")
 	}
 	found := false
-	err = DB.Update(func(tx *bolt.Tx) error {
+	err = update(func(tx *bolt.Tx) error {
 		if argsLen == 1 {
 			err = tx.DeleteBucket([]byte(args[0]))
 			if err == nil {
@@ -28,16 +29,10 @@ func del(args ...string) (res interface{}, err error) {
 			}
 			return err
 		}
-		b := tx.Bucket([]byte(args[0]))
+		b := findBucket(tx, args[:argsLen-1])
 		if b == nil {
 			return nil
 		}
-		for i := 1; i < argsLen-1; i++ {
-			b = b.Bucket([]byte(args[i]))
-			if b == nil {
-				return nil
-			}
-		}
 		key := []byte(args[argsLen-1])
 		err = b.DeleteBucket(key)
 		if err == nil {
@@ -69,7 +64,7 @@ func delGlob(args ...string) (res interface{}, err error) {
 	if err != nil {
 		return nil, err
 	}
-	err = DB.Update(func(tx *bolt.Tx) error {
+	err = update(func(tx *bolt.Tx) error {
 		if argsLen == 1 {
 			c := tx.Cursor()
 			for k, _ := c.First(); k != nil; k, _ = c.Next() {
@@ -82,16 +77,10 @@ func delGlob(args ...string) (res interface{}, err error) {
 				}
 			}
 		} else {
-			b := tx.Bucket([]byte(args[0]))
+			b := findBucket(tx, args[:argsLen-1])
 			if b == nil {
 				return nil
 			}
-			for i := 1; i < argsLen-1; i++ {
-				b = b.Bucket([]byte(args[i]))
-				if b == nil {
-					return nil
-				}
-			}
 			c := b.Cursor()
 			for k, _ := c.First(); k != nil; k, _ = c.Next() {
 				if pattern.Match(string(k)) {
@@ -108,7 +97,7 @@ func delGlob(args ...string) (res interface{}, err error) {
 		}
 		return nil
 	})
-	return count, nil
+	return count, err
 }
 
 func exists(args ...string) (res interface{}, err error) {
@@ -116,22 +105,15 @@ func exists(args ...string) (res interface{}, err error) {
 	if argsLen < 1 {
 		return nil, fmt.Errorf("wrong number of arguments for '%s' command", "exists")
 	}
-	var b *bolt.Bucket
 	found := false
-	err = DB.View(func(tx *bolt.Tx) error {
-		b = tx.Bucket([]byte(args[0]))
-		if b == nil {
-			return nil
-		}
+	err = view(func(tx *bolt.Tx) error {
 		if argsLen == 1 {
-			found = true
+			found = tx.Bucket([]byte(args[0])) != nil
 			return nil
 		}
-		for i := 1; i < argsLen-1; i++ {
-			b = b.Bucket([]byte(args[i]))
-			if b == nil {
-				return nil
-			}
+		b := findBucket(tx, args[:argsLen-1])
+		if b == nil {
+			return nil
 		}
 		lastWord := []byte(args[argsLen-1])
 		if b.Bucket(lastWord) == nil && b.Get(lastWord) == nil {
@@ -147,24 +129,33 @@ func exists(args ...string) (res interface{}, err error) {
 }
 
 func get(args ...string) (res interface{}, err error) {
+	opts, args, err := parseOptions("get", args)
+	if err != nil {
+		return nil, err
+	}
 	argsLen := len(args)
 	if argsLen < 2 {
 		return nil, fmt.Errorf("wrong number of arguments for '%s' command", "get")
 	}
-	err = DB.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(args[0]))
+	dec, explicit, err := valueDecoder(opts["-as"], args[:argsLen-1])
+	if err != nil {
+		return nil, err
+	}
+	err = view(func(tx *bolt.Tx) error {
+		b := findBucket(tx, args[:argsLen-1])
 		if b == nil {
 			return nil
 		}
-		i := 1
-		for ; i < argsLen-1; i++ {
-			b = b.Bucket([]byte(args[i]))
-			if b == nil {
-				return nil
-			}
+		key := []byte(args[argsLen-1])
+		v := b.Get(key)
+		if v == nil || dec == nil {
+			// the value points into the mmap, which is remapped if a later command
+			// queued in the same transaction grows the file, so it must be copied.
+			res = append([]byte(nil), v...)
+			return nil
 		}
-		res = b.Get([]byte(args[i]))
-		return nil
+		res, err = decodeValue(dec, explicit, key, v)
+		return err
 	})
 	if err != nil {
 		return nil, err
@@ -180,23 +171,10 @@ func set(args ...string) (res interface{}, err error) {
 	if argsLen < 3 {
 		return nil, fmt.Errorf("wrong number of arguments for '%s' command", "set")
 	}
-	err = DB.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(args[0]))
-		if b == nil {
-			b, err = tx.CreateBucket([]byte(args[0]))
-			if err != nil {
-				return err
-			}
-		}
-		for i := 1; i < argsLen-2; i++ {
-			subb := b.Bucket([]byte(args[i]))
-			if subb == nil {
-				subb, err = b.CreateBucket([]byte(args[i]))
-				if err != nil {
-					return err
-				}
-			}
-			b = subb
+	err = update(func(tx *bolt.Tx) error {
+		b, err := createBucket(tx, args[:argsLen-2])
+		if err != nil {
+			return err
 		}
 		return b.Put([]byte(args[argsLen-2]), []byte(args[argsLen-1]))
 	})
@@ -206,6 +184,81 @@ func set(args ...string) (res interface{}, err error) {
 	return true, nil
 }
 
+// setnx sets the value only if the key does not exist, and returns whether the value is set.
+func setnx(args ...string) (res interface{}, err error) {
+	argsLen := len(args)
+	if argsLen < 3 {
+		return nil, fmt.Errorf("wrong number of arguments for '%s' command", "setnx")
+	}
+	res = false
+	err = update(func(tx *bolt.Tx) error {
+		b, err := createBucket(tx, args[:argsLen-2])
+		if err != nil {
+			return err
+		}
+		key := []byte(args[argsLen-2])
+		if b.Get(key) != nil || b.Bucket(key) != nil {
+			return nil
+		}
+		res = true
+		return b.Put(key, []byte(args[argsLen-1]))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// cas sets the value only if the current value equals the expected one,
+// and returns whether the value is set.
+func cas(args ...string) (res interface{}, err error) {
+	argsLen := len(args)
+	if argsLen < 4 {
+		return nil, fmt.Errorf("wrong number of arguments for '%s' command", "cas")
+	}
+	res = false
+	err = update(func(tx *bolt.Tx) error {
+		b := findBucket(tx, args[:argsLen-3])
+		if b == nil {
+			return nil
+		}
+		key := []byte(args[argsLen-3])
+		v := b.Get(key)
+		if v == nil || string(v) != args[argsLen-2] {
+			return nil
+		}
+		res = true
+		return b.Put(key, []byte(args[argsLen-1]))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// getset sets the value and returns the old one, or nil if the key does not exist.
+func getset(args ...string) (res interface{}, err error) {
+	argsLen := len(args)
+	if argsLen < 3 {
+		return nil, fmt.Errorf("wrong number of arguments for '%s' command", "getset")
+	}
+	err = update(func(tx *bolt.Tx) error {
+		b, err := createBucket(tx, args[:argsLen-2])
+		if err != nil {
+			return err
+		}
+		key := []byte(args[argsLen-2])
+		if old := b.Get(key); old != nil {
+			res = string(old)
+		}
+		return b.Put(key, []byte(args[argsLen-1]))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
 func buckets(args ...string) (res interface{}, err error) {
 	argsLen := len(args)
 	if argsLen < 1 {
@@ -216,18 +269,12 @@ func buckets(args ...string) (res interface{}, err error) {
 		return
 	}
 	res = []string{}
-	err = DB.View(func(tx *bolt.Tx) error {
+	err = view(func(tx *bolt.Tx) error {
 		if argsLen > 1 {
-			b := tx.Bucket([]byte(args[0]))
+			b := findBucket(tx, args[:argsLen-1])
 			if b == nil {
 				return nil
 			}
-			for i := 1; i < argsLen-1; i++ {
-				b = b.Bucket([]byte(args[i]))
-				if b == nil {
-					return nil
-				}
-			}
 			b.ForEach(func(k, v []byte) error {
 				name := string(k)
 				if pattern.Match(name) && b.Bucket(k) != nil {
@@ -262,17 +309,11 @@ func keys(args ...string) (res interface{}, err error) {
 		return
 	}
 	res = []string{}
-	err = DB.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(args[0]))
+	err = view(func(tx *bolt.Tx) error {
+		b := findBucket(tx, args[:argsLen-1])
 		if b == nil {
 			return nil
 		}
-		for i := 1; i < argsLen-1; i++ {
-			b = b.Bucket([]byte(args[i]))
-			if b == nil {
-				return nil
-			}
-		}
 		b.ForEach(func(k, v []byte) error {
 			key := string(k)
 			if pattern.Match(key) && b.Bucket(k) == nil {
@@ -289,6 +330,10 @@ func keys(args ...string) (res interface{}, err error) {
 }
 
 func keyvalues(args ...string) (res interface{}, err error) {
+	opts, args, err := parseOptions("keyvalues", args)
+	if err != nil {
+		return nil, err
+	}
 	argsLen := len(args)
 	if argsLen < 2 {
 		return nil, fmt.Errorf("wrong number of arguments for '%s' command", "keyvalues")
@@ -297,25 +342,110 @@ func keyvalues(args ...string) (res interface{}, err error) {
 	if err != nil {
 		return
 	}
+	dec, explicit, err := valueDecoder(opts["-as"], args[:argsLen-1])
+	if err != nil {
+		return nil, err
+	}
 	res = map[string]interface{}{}
-	err = DB.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(args[0]))
+	err = view(func(tx *bolt.Tx) error {
+		b := findBucket(tx, args[:argsLen-1])
 		if b == nil {
 			return nil
 		}
-		for i := 1; i < argsLen-1; i++ {
-			b = b.Bucket([]byte(args[i]))
-			if b == nil {
-				return nil
-			}
-		}
-		b.ForEach(func(k, v []byte) error {
+		return b.ForEach(func(k, v []byte) error {
 			key := string(k)
 			if pattern.Match(key) && b.Bucket(k) == nil {
-				res.(map[string]interface{})[key] = string(v)
+				value, err := decodeValue(dec, explicit, k, v)
+				if err != nil {
+					return err
+				}
+				res.(map[string]interface{})[key] = value
 			}
 			return nil
 		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+const (
+	// treeKeyCount is the field which holds the number of keys in a bucket node of tree.
+	treeKeyCount = "keys"
+	// treeChildren is the field which holds the nested buckets (and the keys with -values) of a bucket node,
+	// so that the names never collide with treeKeyCount.
+	treeChildren = "children"
+	// treeValueLimit is the max length of values shown in tree
+	treeValueLimit = 32
+)
+
+// truncateValue truncates the value to at most limit bytes, without splitting a UTF-8 character.
+func truncateValue(v []byte, limit int) string {
+	if len(v) <= limit {
+		return encodeOutput(string(v))
+	}
+	n := limit
+	// a character has at most utf8.UTFMax bytes, so a value which is not UTF-8 is cut at the limit
+	for i := limit; i > limit-utf8.UTFMax && i > 0; i-- {
+		if utf8.RuneStart(v[i]) {
+			n = i
+			break
+		}
+	}
+	return encodeOutput(string(v[:n])) + "..."
+}
+
+// treeNode returns the hierarchy under the bucket at given level.
+// Sub-buckets are included until the maxDepth level is reached, 0 means no limit.
+// The names and the values are encoded with the session wide Encoding.
+func treeNode(b *bolt.Bucket, level, maxDepth int, withValues bool) map[string]interface{} {
+	children := map[string]interface{}{}
+	var count int64
+	b.ForEach(func(k, v []byte) error {
+		if sub := b.Bucket(k); sub != nil {
+			if maxDepth == 0 || level < maxDepth {
+				children[encodeOutput(string(k))] = treeNode(sub, level+1, maxDepth, withValues)
+			}
+			return nil
+		}
+		count++
+		if withValues {
+			children[encodeOutput(string(k))] = truncateValue(v, treeValueLimit)
+		}
+		return nil
+	})
+	return map[string]interface{}{
+		treeKeyCount: count,
+		treeChildren: children,
+	}
+}
+
+func tree(args ...string) (res interface{}, err error) {
+	opts, args, err := parseOptions("tree", args)
+	if err != nil {
+		return nil, err
+	}
+	maxDepth, err := intOption(opts, "-depth", 0)
+	if err != nil {
+		return nil, err
+	}
+	_, withValues := opts["-values"]
+	argsLen := len(args)
+	res = map[string]interface{}{}
+	err = view(func(tx *bolt.Tx) error {
+		if argsLen == 0 {
+			tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+				res.(map[string]interface{})[encodeOutput(string(name))] = treeNode(b, 1, maxDepth, withValues)
+				return nil
+			})
+			return nil
+		}
+		b := findBucket(tx, args)
+		if b == nil {
+			return nil
+		}
+		res = treeNode(b, 0, maxDepth, withValues)
 		return nil
 	})
 	if err != nil {
@@ -324,26 +454,25 @@ func keyvalues(args ...string) (res interface{}, err error) {
 	return
 }
 
-func stats(_ ...string) (res interface{}, err error) {
+// intFields returns the integer fields of a stats struct
+func intFields(stat interface{}) map[string]interface{} {
 	info := map[string]interface{}{}
-	stat := DB.Stats()
 	val := reflect.ValueOf(stat)
 	for i := 0; i < val.NumField(); i++ {
 		valField := val.Field(i)
 		typeField := val.Type().Field(i)
-		if typeField.Type.Name() == "int" {
+		// newer bbolt uses int64 counters, and time.Duration is an int64 as well
+		if kind := typeField.Type.Kind(); kind == reflect.Int || kind == reflect.Int64 {
 			info[typeField.Name] = valField.Int()
 		}
 	}
-	val = reflect.ValueOf(stat.TxStats)
-	info["TxStats"] = map[string]interface{}{}
-	for i := 0; i < val.NumField(); i++ {
-		valField := val.Field(i)
-		typeField := val.Type().Field(i)
-		if typeField.Type.Name() == "int" || typeField.Type.Name() == "Duration" {
-			info["TxStats"].(map[string]interface{})[typeField.Name] = valField.Int()
-		}
-	}
+	return info
+}
+
+func stats(_ ...string) (res interface{}, err error) {
+	stat := DB.Stats()
+	info := intFields(stat)
+	info["TxStats"] = intFields(stat.TxStats)
 	return info, nil
 }
 
@@ -372,16 +501,82 @@ type cmd func(...string) (interface{}, error)
 
 // CmdMap holds the relation between command name and its implement function
 var CmdMap = map[string]cmd{
-	"del":       del,
-	"delglob":   delGlob,
-	"exists":    exists,
-	"get":       get,
-	"help":      help,
-	"set":       set,
-	"buckets":   buckets,
-	"keys":      keys,
-	"keyvalues": keyvalues,
-	"stats":     stats,
+	"del":         del,
+	"delglob":     delGlob,
+	"exists":      exists,
+	"strlen":      strlen,
+	"type":        typeCmd,
+	"meta":        meta,
+	"get":         get,
+	"help":        help,
+	"set":         set,
+	"setnx":       setnx,
+	"cas":         cas,
+	"getset":      getset,
+	"mget":        mget,
+	"mset":        mset,
+	"incr":        incr,
+	"decr":        decr,
+	"incrby":      incrby,
+	"nextseq":     nextseq,
+	"setseq":      setseq,
+	"buckets":     buckets,
+	"keys":        keys,
+	"keyvalues":   keyvalues,
+	"tree":        tree,
+	"scan":        scan,
+	"search":      search,
+	"range":       rangeCmd,
+	"stats":       stats,
+	"bucketstats": bucketstats,
+	"bigkeys":     bigkeys,
+	"info":        info,
+	"pages":       pages,
+	"page":        page,
+	"encoding":    encoding,
+	"output":      output,
+	"dump":        dump,
+	"restore":     restore,
+	"compact":     compact,
+	"backup":      backup,
+	"check":       check,
+	"multi":       multi,
+	"discard":     discard,
+	"copy":        copyCmd,
+	"move":        move,
+	"rename":      rename,
+	"decoder":     decoderCmd,
+	"cd":          cd,
+	"pwd":         pwd,
+	"ls":          ls,
+}
+
+// writeCmds holds the commands which modify the database.
+// They are rejected when the database is opened in read-only mode.
+var writeCmds = map[string]bool{
+	"cas":     true,
+	"copy":    true,
+	"decr":    true,
+	"del":     true,
+	"delglob": true,
+	"getset":  true,
+	"incr":    true,
+	"incrby":  true,
+	"move":    true,
+	"mset":    true,
+	"nextseq": true,
+	"rename":  true,
+	"restore": true,
+	"set":     true,
+	"setnx":   true,
+	"setseq":  true,
+}
+
+func checkWritable(name string) error {
+	if writeCmds[name] && DB.IsReadOnly() {
+		return fmt.Errorf("can't run '%s' command, the database is opened in read-only mode", name)
+	}
+	return nil
 }
 
 // Format ["o1", "o2"] to string
@@ -396,6 +591,33 @@ func formatListToStr(list []string) string {
 	return strings.Join(padded, "\n")
 }
 
+// Format [true, "o2", ["a", "b"]] to string
+// 1) true\n
+// 2) "o2"\n
+// 3) 1) "a"\n
+//    2) "b"
+func formatResultListToStr(list []interface{}) (string, error) {
+	paddingNum := strconv.Itoa(int(math.Log10(float64(len(list)))) + 1)
+	formatted := make([]string, len(list))
+	for i, res := range list {
+		s, err := formatResult(res)
+		if err != nil {
+			return "", err
+		}
+		// only an empty list or map is formatted to nothing, show it like the repl does
+		if s == "" {
+			s = "(empty list or set)"
+		}
+		prefix := fmt.Sprintf("%"+paddingNum+"d) ", i+1)
+		lines := strings.Split(s, "\n")
+		for j := 1; j < len(lines); j++ {
+			lines[j] = strings.Repeat(" ", len(prefix)) + lines[j]
+		}
+		formatted[i] = prefix + strings.Join(lines, "\n")
+	}
+	return strings.Join(formatted, "\n"), nil
+}
+
 // Format {"a": "10", "b": "20", "c": {"c1": 30}} to string
 // a) "10"\n
 // b) "20"\n
@@ -412,49 +634,115 @@ func formatMapToStr(collection map[string]interface{}, prefix string) string {
 	sort.Strings(keys)
 	for i, k := range keys {
 		switch v := collection[k].(type) {
-		case int64:
+		case int64, uint64, bool:
 			formatted[i] = fmt.Sprintf(`%s%s) %v`, prefix, k, v)
 		case string:
 			formatted[i] = fmt.Sprintf(`%s%s) "%s"`, prefix, k, v)
+		case Decoded:
+			s := v.String()
+			if strings.Contains(s, "\n") {
+				formatted[i] = fmt.Sprintf("%s%s)\n%s    %s", prefix, k, prefix, strings.Replace(s, "\n", "\n"+prefix+"    ", -1))
+			} else {
+				formatted[i] = fmt.Sprintf(`%s%s) %s`, prefix, k, s)
+			}
 		case map[string]interface{}:
-			nestedMap := formatMapToStr(v, prefix+"    ")
-			formatted[i] = fmt.Sprintf("%s%s)\n%s", prefix, k, nestedMap)
+			formatted[i] = fmt.Sprintf("%s%s)", prefix, k)
+			if len(v) != 0 {
+				formatted[i] += "\n" + formatMapToStr(v, prefix+"    ")
+			}
+		case []string, []interface{}:
+			formatted[i] = fmt.Sprintf("%s%s)", prefix, k)
+			// the lists in a map only hold the types supported by formatResult
+			list, _ := formatResult(v)
+			if list != "" {
+				formatted[i] += "\n" + prefix + "    " + strings.Replace(list, "\n", "\n"+prefix+"    ", -1)
+			}
 		}
 		i++
 	}
 	return strings.Join(formatted, "\n")
 }
 
-// ExecCmdInCli run given cmd with args, return formatted string according to cmd result.
-func ExecCmdInCli(cmd string, args ...string) string {
-	f, ok := CmdMap[strings.ToLower(cmd)]
+// execCmd runs given cmd with args typed in the command line.
+// The arguments are decoded and the result is encoded with the session wide Encoding.
+func execCmd(cmd string, args ...string) (interface{}, error) {
+	name := strings.ToLower(cmd)
+	f, ok := CmdMap[name]
 	if !ok {
-		return fmt.Sprintf("ERR unknown command '%s'", cmd)
+		return nil, fmt.Errorf("unknown command '%s'", cmd)
+	}
+	if err := checkWritable(name); err != nil {
+		return nil, err
+	}
+	plain := plainCmds[name]
+	args, err := resolveArgs(name, args, !plain)
+	if err != nil {
+		return nil, err
+	}
+	if queueCmd(name, args) {
+		return statusQueued, nil
 	}
 	// keep the case unchanged so that we could distinguish
 	// uppercase key from lowercase key.
 	res, err := f(args...)
 	if err != nil {
-		return fmt.Sprintf("ERR %v", err)
+		return nil, err
 	}
+	return encodeCmdResult(name, res), nil
+}
+
+// formatResult formats the result of a command like what redis-cli does.
+func formatResult(res interface{}) (string, error) {
 	switch res := res.(type) {
+	case nil:
+		return "(nil)", nil
 	case bool:
-		return strconv.FormatBool(res)
+		return strconv.FormatBool(res), nil
 	case []byte:
-		return fmt.Sprintf("\"%s\"", string(res))
+		return fmt.Sprintf("\"%s\"", string(res)), nil
 	case string:
-		return fmt.Sprintf("\"%s\"", res)
+		return fmt.Sprintf("\"%s\"", res), nil
 	case []string:
-		return formatListToStr(res)
+		return formatListToStr(res), nil
+	case []interface{}:
+		return formatResultListToStr(res)
 	case map[string]interface{}:
-		return formatMapToStr(res, "")
+		return formatMapToStr(res, ""), nil
 	case int:
-		return strconv.Itoa(res)
+		return strconv.Itoa(res), nil
+	case int64:
+		return strconv.FormatInt(res, 10), nil
+	case uint64:
+		return strconv.FormatUint(res, 10), nil
 	case HelpOutput:
-		return fmt.Sprintf("%s", res)
+		return fmt.Sprintf("%s", res), nil
+	case Status:
+		return string(res), nil
+	case Decoded:
+		return res.String(), nil
 	default:
-		panic(fmt.Sprintf(
-			"The type of result returns from command '%s' with args %v is unsupported",
-			cmd, args))
+		return "", fmt.Errorf("the type of result %T is unsupported", res)
+	}
+}
+
+// ExecCmdInCli run given cmd with args, return formatted string according to cmd result.
+func ExecCmdInCli(cmd string, args ...string) string {
+	out, _ := runCmdInCli(cmd, args...)
+	return out
+}
+
+// runCmdInCli is like ExecCmdInCli, and also reports whether the command succeeded.
+func runCmdInCli(cmd string, args ...string) (string, bool) {
+	res, err := execCmd(cmd, args...)
+	if OutputFormat != outputText {
+		return formatJSON(res, err), err == nil
+	}
+	if err != nil {
+		return fmt.Sprintf("ERR %v", err), false
+	}
+	s, err := formatResult(res)
+	if err != nil {
+		return fmt.Sprintf("ERR command '%s' with args %v: %v", cmd, args, err), false
 	}
+	return s, true
 }
@@ -1,9 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
 
 	bolt "go.etcd.io/bbolt"
@@ -251,3 +258,1025 @@ func (suite *CmdSuite) TestStats() {
 	txStatusWrite, _ := info.(map[string]interface{})["TxStats"].(map[string]interface{})["Write"].(int64)
 	assert.True(suite.T(), txStatusWrite > 0)
 }
+
+func (suite *CmdSuite) TestEncoding() {
+	defer setEncoding(encodingRaw)
+	assert.Equal(suite.T(), `"raw"`, ExecCmdInCli("encoding"))
+	assert.Equal(suite.T(), "ERR unknown encoding 'utf16', available encodings: raw, hex, base64, escaped",
+		ExecCmdInCli("encoding", "utf16"))
+
+	assert.Equal(suite.T(), "true", ExecCmdInCli("set", "bucket", "0x00ff", "b64:AAE="))
+	assert.Equal(suite.T(), "\"\x00\x01\"", ExecCmdInCli("get", "bucket", "0x00ff"))
+	assert.Equal(suite.T(), "ERR invalid hex argument 'zz': encoding/hex: invalid byte: U+007A 'z'",
+		ExecCmdInCli("get", "bucket", "0xzz"))
+
+	assert.Equal(suite.T(), `"hex"`, ExecCmdInCli("encoding", "HEX"))
+	assert.Equal(suite.T(), `"0001"`, ExecCmdInCli("get", "6275636b6574", "00ff"))
+	assert.Equal(suite.T(), `1) "00ff"`, ExecCmdInCli("keys", "6275636b6574", "raw:*"))
+	assert.Equal(suite.T(), `00ff) "0001"`, ExecCmdInCli("keyvalues", "raw:bucket", "raw:*"))
+
+	assert.Equal(suite.T(), `"base64"`, ExecCmdInCli("encoding", "base64"))
+	assert.Equal(suite.T(), `"AAE="`, ExecCmdInCli("get", "YnVja2V0", "AP8="))
+
+	assert.Equal(suite.T(), `"escaped"`, ExecCmdInCli("encoding", "escaped"))
+	assert.Equal(suite.T(), `"\x00\x01"`, ExecCmdInCli("get", "bucket", `\x00\xff`))
+	assert.Equal(suite.T(), "true", ExecCmdInCli("set", "bucket", "key", "tab\there \"quoted\" 中文"))
+	assert.Equal(suite.T(), `"tab\there \"quoted\" 中文"`, ExecCmdInCli("get", "bucket", "key"))
+
+	// stats are labels instead of data
+	assert.Equal(suite.T(), `"hex"`, ExecCmdInCli("encoding", "hex"))
+	assert.Contains(suite.T(), ExecCmdInCli("stats"), "TxStats)")
+}
+
+func (suite *CmdSuite) TestReadOnly() {
+	assert.Equal(suite.T(), "true", ExecCmdInCli("set", "bucket", "key", "value"))
+	DB.Close()
+	*readOnly = true
+	defer func() { *readOnly = false }()
+	initDB(suite.dbPath)
+
+	assert.True(suite.T(), DB.IsReadOnly())
+	assert.Equal(suite.T(), `"value"`, ExecCmdInCli("get", "bucket", "key"))
+	for _, args := range [][]string{
+		{"set", "bucket", "key", "value"},
+		{"del", "bucket", "key"},
+		{"delglob", "bucket", "*"},
+		{"copy", "/bucket", "/bucket2"},
+		{"move", "/bucket", "/bucket2"},
+		{"rename", "/bucket", "bucket2"},
+		{"setnx", "bucket", "key", "value"},
+		{"cas", "bucket", "key", "value", "new"},
+		{"getset", "bucket", "key", "value"},
+		{"mset", "bucket", "key", "value"},
+		{"incr", "bucket", "counter"},
+		{"nextseq", "bucket"},
+	} {
+		assert.Equal(suite.T(),
+			"ERR can't run '"+args[0]+"' command, the database is opened in read-only mode",
+			ExecCmdInCli(args[0], args[1:]...))
+	}
+	assert.Equal(suite.T(), `"value"`, ExecCmdInCli("get", "bucket", "key"))
+}
+
+func (suite *CmdSuite) TestOutput() {
+	defer setOutputFormat(outputText)
+	assert.Equal(suite.T(), `"text"`, ExecCmdInCli("output"))
+	assert.Equal(suite.T(), "ERR unknown output format 'xml', available formats: text, json, ndjson",
+		ExecCmdInCli("output", "xml"))
+
+	assert.Equal(suite.T(), `{"ok":true,"result":"ndjson"}`, ExecCmdInCli("output", "ndjson"))
+	assert.Equal(suite.T(), `{"ok":true,"result":""}`, ExecCmdInCli("get", "bucket", "key"))
+	assert.Equal(suite.T(), `{"ok":true,"result":true}`, ExecCmdInCli("set", "bucket", "key", "<value>"))
+	assert.Equal(suite.T(), `{"ok":true,"result":"<value>"}`, ExecCmdInCli("get", "bucket", "key"))
+	assert.Equal(suite.T(), `{"ok":true,"result":["key"]}`, ExecCmdInCli("keys", "bucket", "*"))
+	assert.Equal(suite.T(), `{"ok":true,"result":[]}`, ExecCmdInCli("keys", "bucket", "non-exist"))
+	assert.Equal(suite.T(), `{"ok":true,"result":{"key":"<value>"}}`, ExecCmdInCli("keyvalues", "bucket", "*"))
+	assert.Equal(suite.T(), `{"ok":true,"result":1}`, ExecCmdInCli("delglob", "bucket", "*"))
+	assert.Equal(suite.T(), `{"ok":true,"result":true}`, ExecCmdInCli("set", "bucket", "key", "\xff\x00"))
+	assert.Equal(suite.T(), `{"ok":true,"result":{"base64":"/wA="}}`, ExecCmdInCli("get", "bucket", "key"))
+	assert.Equal(suite.T(), `{"ok":true,"result":{"key":{"base64":"/wA="}}}`, ExecCmdInCli("keyvalues", "bucket", "*"))
+	assert.Equal(suite.T(), `{"ok":true,"result":true}`, ExecCmdInCli("set", "bucket", "\xfe", "value"))
+	assert.Equal(suite.T(), `{"ok":true,"result":["key",{"base64":"/g=="}]}`, ExecCmdInCli("keys", "bucket", "*"))
+	assert.Equal(suite.T(),
+		`{"ok":false,"result":null,"error":"\"\\xfe\" is not valid UTF-8, use another encoding like hex to output it in json"}`,
+		ExecCmdInCli("keyvalues", "bucket", "*"))
+	assert.Equal(suite.T(), `{"ok":true,"result":2}`, ExecCmdInCli("delglob", "bucket", "*"))
+	assert.Equal(suite.T(), `{"ok":true,"result":"Invalid command: non-exist"}`, ExecCmdInCli("help", "non-exist"))
+	assert.Equal(suite.T(), `{"ok":false,"result":null,"error":"unknown command 'non-exist'"}`,
+		ExecCmdInCli("non-exist"))
+	assert.Equal(suite.T(), `{"ok":false,"result":null,"error":"wrong number of arguments for 'get' command"}`,
+		ExecCmdInCli("get"))
+
+	assert.Equal(suite.T(), "{\n  \"ok\": true,\n  \"result\": \"json\"\n}", ExecCmdInCli("output", "json"))
+	assert.Equal(suite.T(), "{\n  \"ok\": true,\n  \"result\": false\n}", ExecCmdInCli("exists", "bucket", "key"))
+}
+
+func (suite *CmdSuite) TestDumpAndRestore() {
+	dumpPath := suite.dbPath + ".json"
+	defer os.Remove(dumpPath)
+	assert.Equal(suite.T(), "ERR wrong number of arguments for 'dump' command", ExecCmdInCli("dump"))
+	assert.Equal(suite.T(), "buckets) 0\nkeys) 0", ExecCmdInCli("dump", dumpPath))
+	assert.Equal(suite.T(), "ERR can't dump the database to itself", ExecCmdInCli("dump", suite.dbPath))
+
+	DB.Update(func(tx *bolt.Tx) error {
+		b, _ := tx.CreateBucket([]byte("bucket"))
+		b.Put([]byte("key"), []byte("value"))
+		b.Put([]byte("empty"), []byte(""))
+		b.SetSequence(42)
+		b, _ = b.CreateBucket([]byte{0xff, 0x00})
+		b.Put([]byte("binary"), []byte{0x00, 0x01, 0xfe})
+		tx.CreateBucket([]byte("empty bucket"))
+		return nil
+	})
+	assert.Equal(suite.T(), "buckets) 3\nkeys) 3", ExecCmdInCli("dump", dumpPath))
+	data, _ := ioutil.ReadFile(dumpPath)
+	assert.Contains(suite.T(), string(data), `"name": {
+            "base64": "/wA="
+          },`)
+	assert.Contains(suite.T(), string(data), `"key": "binary",
+              "value": {
+                "base64": "AAH+"
+              }`)
+
+	assert.Equal(suite.T(), "2", ExecCmdInCli("delglob", "*"))
+	assert.Equal(suite.T(), "buckets) 3\nkeys) 3", ExecCmdInCli("restore", dumpPath))
+	assert.Equal(suite.T(), `"value"`, ExecCmdInCli("get", "bucket", "key"))
+	assert.Equal(suite.T(), "true", ExecCmdInCli("exists", "bucket", "empty"))
+	assert.Equal(suite.T(), "\"\x00\x01\xfe\"", ExecCmdInCli("get", "bucket", "\xff\x00", "binary"))
+	assert.Equal(suite.T(), "true", ExecCmdInCli("exists", "empty bucket"))
+	DB.View(func(tx *bolt.Tx) error {
+		assert.Equal(suite.T(), uint64(42), tx.Bucket([]byte("bucket")).Sequence())
+		return nil
+	})
+
+	// restore overwrites the existing keys
+	assert.Equal(suite.T(), "true", ExecCmdInCli("set", "bucket", "key", "new value"))
+	assert.Equal(suite.T(), "true", ExecCmdInCli("set", "bucket", "new key", "value"))
+	assert.Equal(suite.T(), "buckets) 3\nkeys) 3", ExecCmdInCli("restore", dumpPath))
+	assert.Equal(suite.T(), `"value"`, ExecCmdInCli("get", "bucket", "key"))
+	assert.Equal(suite.T(), `"value"`, ExecCmdInCli("get", "bucket", "new key"))
+
+	ioutil.WriteFile(dumpPath, []byte(`{"version": 2, "buckets": []}`), 0600)
+	assert.Equal(suite.T(), "ERR unsupported dump version 2", ExecCmdInCli("restore", dumpPath))
+}
+
+func (suite *CmdSuite) TestTree() {
+	assert.Equal(suite.T(), "", ExecCmdInCli("tree"))
+	assert.Equal(suite.T(), "", ExecCmdInCli("tree", "non-exist"))
+	assert.Equal(suite.T(), "ERR option '-depth' requires a value", ExecCmdInCli("tree", "-depth"))
+	assert.Equal(suite.T(), "ERR value of option '-depth' should be a non-negative integer",
+		ExecCmdInCli("tree", "-depth", "x"))
+
+	DB.Update(func(tx *bolt.Tx) error {
+		b, _ := tx.CreateBucket([]byte("bucket"))
+		b.Put([]byte("key"), []byte("value"))
+		b.Put([]byte("long"), []byte(strings.Repeat("v", 40)))
+		b, _ = b.CreateBucket([]byte("subbucket"))
+		b.Put([]byte("key"), []byte("value"))
+		b, _ = b.CreateBucket([]byte("subbucket"))
+		tx.CreateBucket([]byte("empty"))
+		return nil
+	})
+	assert.Equal(suite.T(), strings.Join([]string{
+		"bucket)",
+		"    children)",
+		"        subbucket)",
+		"            children)",
+		"                subbucket)",
+		"                    children)",
+		"                    keys) 0",
+		"            keys) 1",
+		"    keys) 2",
+		"empty)",
+		"    children)",
+		"    keys) 0",
+	}, "\n"), ExecCmdInCli("tree"))
+	assert.Equal(suite.T(), "bucket)\n    children)\n    keys) 2\nempty)\n    children)\n    keys) 0",
+		ExecCmdInCli("tree", "-depth", "1"))
+	assert.Equal(suite.T(), strings.Join([]string{
+		"children)",
+		`    key) "value"`,
+		`    long) "` + strings.Repeat("v", 32) + `..."`,
+		"    subbucket)",
+		"        children)",
+		`            key) "value"`,
+		"        keys) 1",
+		"keys) 2",
+	}, "\n"), ExecCmdInCli("tree", "-values", "bucket", "-depth", "1"))
+	assert.Equal(suite.T(), "children)\n    key) \"value\"\n    subbucket)\n        children)\n        keys) 0\nkeys) 1",
+		ExecCmdInCli("tree", "bucket", "subbucket", "-values"))
+
+	// the counts don't collide with the keys
+	ExecCmdInCli("set", "empty", "keys", "value")
+	ExecCmdInCli("set", "empty", "children", "value")
+	res, err := execCmd("tree", "empty", "-values")
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), map[string]interface{}{
+		"keys":     int64(2),
+		"children": map[string]interface{}{"keys": "value", "children": "value"},
+	}, res)
+
+	// values are truncated without splitting a character
+	ExecCmdInCli("set", "empty", "utf8", "a"+strings.Repeat("é", 20))
+	res, _ = execCmd("tree", "empty", "-values")
+	assert.Equal(suite.T(), "a"+strings.Repeat("é", 15)+"...", res.(map[string]interface{})["children"].(map[string]interface{})["utf8"])
+
+	defer setEncoding(encodingRaw)
+	setEncoding(encodingHex)
+	assert.Equal(suite.T(), "children)\n    6b6579) \"76616c7565\"\n    7375626275636b6574)\n        children)\n        keys) 0\nkeys) 1",
+		ExecCmdInCli("tree", "6275636b6574", "7375626275636b6574", "-values"))
+	assert.Equal(suite.T(), "6275636b6574)\n    children)\n    keys) 2\n656d707479)\n    children)\n    keys) 3",
+		ExecCmdInCli("tree", "-depth", "1"))
+}
+
+func (suite *CmdSuite) TestMulti() {
+	assert.Equal(suite.T(), "ERR exec without multi", ExecCmdInCli("exec"))
+	assert.Equal(suite.T(), "ERR discard without multi", ExecCmdInCli("discard"))
+
+	assert.Equal(suite.T(), "true", ExecCmdInCli("multi"))
+	assert.Equal(suite.T(), "ERR multi calls can not be nested", ExecCmdInCli("multi"))
+	assert.Equal(suite.T(), "QUEUED", ExecCmdInCli("set", "bucket", "key", "value"))
+	assert.Equal(suite.T(), "QUEUED", ExecCmdInCli("set", "bucket", "key2", "value2"))
+	assert.Equal(suite.T(), "QUEUED", ExecCmdInCli("get", "bucket", "key"))
+	assert.Equal(suite.T(), "QUEUED", ExecCmdInCli("keys", "bucket", "*"))
+	assert.Equal(suite.T(), "QUEUED", ExecCmdInCli("exists", "bucket", "key3"))
+	assert.Equal(suite.T(), "1) true\n2) true\n3) \"value\"\n4) 1) \"key\"\n   2) \"key2\"\n5) false",
+		ExecCmdInCli("exec"))
+	assert.Equal(suite.T(), `"value2"`, ExecCmdInCli("get", "bucket", "key2"))
+
+	assert.Equal(suite.T(), "true", ExecCmdInCli("multi"))
+	assert.Equal(suite.T(), "QUEUED", ExecCmdInCli("del", "bucket", "key"))
+	assert.Equal(suite.T(), "true", ExecCmdInCli("discard"))
+	assert.Equal(suite.T(), `"value"`, ExecCmdInCli("get", "bucket", "key"))
+
+	// rollback on error
+	assert.Equal(suite.T(), "true", ExecCmdInCli("multi"))
+	assert.Equal(suite.T(), "QUEUED", ExecCmdInCli("del", "bucket", "key"))
+	assert.Equal(suite.T(), "QUEUED", ExecCmdInCli("set", "bucket", "key3", "value3"))
+	assert.Equal(suite.T(), "QUEUED", ExecCmdInCli("get", "bucket"))
+	assert.Equal(suite.T(), "QUEUED", ExecCmdInCli("set", "bucket", "key4", "value4"))
+	assert.Equal(suite.T(),
+		"ERR command 3 (get) failed, transaction is rolled back: wrong number of arguments for 'get' command",
+		ExecCmdInCli("exec"))
+	assert.Equal(suite.T(), `"value"`, ExecCmdInCli("get", "bucket", "key"))
+	assert.Equal(suite.T(), "false", ExecCmdInCli("exists", "bucket", "key3"))
+	assert.Equal(suite.T(), "false", ExecCmdInCli("exists", "bucket", "key4"))
+
+	assert.Equal(suite.T(), "true", ExecCmdInCli("multi"))
+	assert.Equal(suite.T(), "", ExecCmdInCli("exec"))
+
+	// the value got by a queued command outlives the remapping caused by the later commands
+	large := strings.Repeat("v", 30*1024)
+	assert.Equal(suite.T(), "true", ExecCmdInCli("set", "large", "first", large))
+	assert.Equal(suite.T(), "true", ExecCmdInCli("multi"))
+	assert.Equal(suite.T(), "QUEUED", ExecCmdInCli("get", "large", "first"))
+	for i := 0; i < 400; i++ {
+		assert.Equal(suite.T(), "QUEUED", ExecCmdInCli("set", "large", "key"+strconv.Itoa(i), large))
+	}
+	assert.True(suite.T(), strings.HasPrefix(ExecCmdInCli("exec"), "  1) \""+large+"\"\n  2) true\n"))
+
+	// each result is encoded like the result of the queued command
+	defer setEncoding(encodingRaw)
+	defer setOutputFormat(outputText)
+	setEncoding(encodingHex)
+	assert.Equal(suite.T(), "true", ExecCmdInCli("multi"))
+	assert.Equal(suite.T(), "QUEUED", ExecCmdInCli("get", "6275636b6574", "6b6579"))
+	assert.Equal(suite.T(), "QUEUED", ExecCmdInCli("pwd"))
+	assert.Equal(suite.T(), "1) \"76616c7565\"\n2) \"/\"", ExecCmdInCli("exec"))
+	setEncoding(encodingRaw)
+	setOutputFormat(outputNDJSON)
+	assert.Equal(suite.T(), `{"ok":true,"result":true}`, ExecCmdInCli("multi"))
+	assert.Equal(suite.T(), `{"ok":true,"result":null,"status":"QUEUED"}`, ExecCmdInCli("get", "bucket", "key"))
+	assert.Equal(suite.T(), `{"ok":true,"result":["value"]}`, ExecCmdInCli("exec"))
+	setOutputFormat(outputText)
+
+	// exec without write commands works in read-only mode
+	DB.Close()
+	*readOnly = true
+	defer func() { *readOnly = false }()
+	initDB(suite.dbPath)
+	assert.Equal(suite.T(), "true", ExecCmdInCli("multi"))
+	assert.Equal(suite.T(), "ERR can't run 'set' command, the database is opened in read-only mode",
+		ExecCmdInCli("set", "bucket", "key", "value"))
+	assert.Equal(suite.T(), "QUEUED", ExecCmdInCli("get", "bucket", "key"))
+	assert.Equal(suite.T(), `1) "value"`, ExecCmdInCli("exec"))
+}
+
+func (suite *CmdSuite) TestScan() {
+	assert.Equal(suite.T(), "ERR wrong number of arguments for 'scan' command", ExecCmdInCli("scan", "-reverse"))
+	assert.Equal(suite.T(), "1) \"\"\n2) (empty list or set)", ExecCmdInCli("scan", "non-exist"))
+
+	DB.Update(func(tx *bolt.Tx) error {
+		b, _ := tx.CreateBucket([]byte("bucket"))
+		for i := 0; i < 10; i++ {
+			suffix := strconv.Itoa(i)
+			b.Put([]byte("key_"+suffix), []byte("value_"+suffix))
+		}
+		b.Put([]byte("other"), []byte("value"))
+		b.CreateBucket([]byte("key_5_bucket"))
+		b, _ = b.CreateBucket([]byte("subbucket"))
+		b.Put([]byte("key"), []byte("value"))
+		return nil
+	})
+	assert.Equal(suite.T(), "1) \"key_2\"\n2) 1) \"key_0\"\n   2) \"key_1\"",
+		ExecCmdInCli("scan", "bucket", "-limit", "2"))
+	assert.Equal(suite.T(), "1) \"key_4\"\n2) 1) \"key_2\"\n   2) \"key_3\"",
+		ExecCmdInCli("scan", "bucket", "-limit", "2", "-cursor", "key_2"))
+	assert.Equal(suite.T(), "1) \"\"\n2) 1) \"key_8\"\n   2) \"key_9\"\n   3) \"other\"",
+		ExecCmdInCli("scan", "bucket", "-cursor", "key_8"))
+	assert.Equal(suite.T(), "1) \"\"\n2) 1) \"key_5\"\n   2) \"key_6\"",
+		ExecCmdInCli("scan", "-start", "key_5", "-end", "key_7", "bucket"))
+	assert.Equal(suite.T(), "1) \"\"\n2) 1) \"key_6\"\n   2) \"key_5\"",
+		ExecCmdInCli("scan", "-start", "key_5", "-end", "key_7", "bucket", "-reverse"))
+	// a cursor outside of the bounds starts from the bound
+	assert.Equal(suite.T(), "1) \"\"\n2) 1) \"key_5\"\n   2) \"key_6\"",
+		ExecCmdInCli("scan", "-start", "key_5", "-end", "key_7", "bucket", "-cursor", "key_1"))
+	assert.Equal(suite.T(), "1) \"\"\n2) 1) \"key_6\"\n   2) \"key_5\"",
+		ExecCmdInCli("scan", "-start", "key_5", "-end", "key_7", "bucket", "-cursor", "other", "-reverse"))
+	assert.Equal(suite.T(), "1) \"\"\n2) 1) \"key_3\"", ExecCmdInCli("scan", "bucket", "-prefix", "key_3", "-cursor", "a"))
+	assert.Equal(suite.T(), "1) \"key_7\"\n2) 1) \"other\"\n   2) \"key_9\"\n   3) \"key_8\"",
+		ExecCmdInCli("scan", "bucket", "-reverse", "-limit", "3"))
+	assert.Equal(suite.T(), "1) \"key_4\"\n2) 1) \"key_7\"\n   2) \"key_6\"\n   3) \"key_5\"",
+		ExecCmdInCli("scan", "bucket", "-reverse", "-limit", "3", "-cursor", "key_7"))
+	assert.Equal(suite.T(), "1) \"\"\n2) 1) \"key\"", ExecCmdInCli("scan", "bucket", "subbucket", "-prefix", "k"))
+	assert.Equal(suite.T(), "1) \"\"\n2) 1) \"key_3\"", ExecCmdInCli("scan", "bucket", "-prefix", "key_3"))
+	assert.Equal(suite.T(), "1) \"\"\n2) 1) \"key_3\"",
+		ExecCmdInCli("scan", "bucket", "-prefix", "key_3", "-reverse"))
+	assert.Equal(suite.T(), "1) \"\"\n2) 1) \"key_8\"\n   2) \"key_9\"",
+		ExecCmdInCli("scan", "bucket", "-prefix", "key_", "-start", "key_8"))
+	assert.Equal(suite.T(), "1) \"key_1\"\n2) 1) \"key_0\"\n   2) \"value_0\"",
+		ExecCmdInCli("range", "bucket", "-limit", "1"))
+
+	defer setEncoding(encodingRaw)
+	setEncoding(encodingHex)
+	assert.Equal(suite.T(), "1) \"6b65795f31\"\n2) 1) \"6b65795f30\"\n   2) \"76616c75655f30\"",
+		ExecCmdInCli("range", "6275636b6574", "-limit", "1", "-prefix", "6b6579"))
+}
+
+func (suite *CmdSuite) TestCurrentBucket() {
+	defer func() { CurBucket = []string{} }()
+	assert.Equal(suite.T(), `"/"`, ExecCmdInCli("pwd"))
+	assert.Equal(suite.T(), "ERR no such bucket: /bucket", ExecCmdInCli("cd", "bucket"))
+
+	DB.Update(func(tx *bolt.Tx) error {
+		b, _ := tx.CreateBucket([]byte("bucket"))
+		b.Put([]byte("key"), []byte("value"))
+		b, _ = b.CreateBucket([]byte("subbucket"))
+		b.Put([]byte("key"), []byte("subvalue"))
+		tx.CreateBucket([]byte("bucket2"))
+		return nil
+	})
+	assert.Equal(suite.T(), "1) \"bucket/\"\n2) \"bucket2/\"", ExecCmdInCli("ls"))
+	assert.Equal(suite.T(), `"/bucket"`, ExecCmdInCli("cd", "bucket"))
+	assert.Equal(suite.T(), "1) \"key\"\n2) \"subbucket/\"", ExecCmdInCli("ls"))
+	assert.Equal(suite.T(), `1) "key"`, ExecCmdInCli("ls", "subbucket"))
+	assert.Equal(suite.T(), `"value"`, ExecCmdInCli("get", "key"))
+	assert.Equal(suite.T(), `"subvalue"`, ExecCmdInCli("get", "subbucket", "key"))
+	assert.Equal(suite.T(), "true", ExecCmdInCli("set", "key2", "value2"))
+	assert.Equal(suite.T(), "1) \"key\"\n2) \"key2\"", ExecCmdInCli("keys", "*"))
+	assert.Equal(suite.T(), `1) "subbucket"`, ExecCmdInCli("buckets", "*"))
+	assert.Equal(suite.T(), "children)\n    subbucket)\n        children)\n        keys) 1\nkeys) 2", ExecCmdInCli("tree"))
+	// write commands need explicit arguments
+	assert.Equal(suite.T(), "ERR wrong number of arguments for 'del' command", ExecCmdInCli("del"))
+	assert.Equal(suite.T(), "true", ExecCmdInCli("del", "key2"))
+
+	assert.Equal(suite.T(), `"/bucket/subbucket"`, ExecCmdInCli("cd", "subbucket"))
+	assert.Equal(suite.T(), `"/bucket/subbucket"`, ExecCmdInCli("pwd"))
+	assert.Equal(suite.T(), `"subvalue"`, ExecCmdInCli("get", "key"))
+	assert.Equal(suite.T(), "ERR no such bucket: /bucket/subbucket/key", ExecCmdInCli("cd", "key"))
+	assert.Equal(suite.T(), `"/bucket2"`, ExecCmdInCli("cd", "..", "..", "bucket2"))
+	assert.Equal(suite.T(), `"/"`, ExecCmdInCli("cd", ".."))
+	assert.Equal(suite.T(), `"/"`, ExecCmdInCli("cd", ".."))
+	assert.Equal(suite.T(), `"/bucket/subbucket"`, ExecCmdInCli("cd", "bucket", "subbucket"))
+	assert.Equal(suite.T(), `"/"`, ExecCmdInCli("cd"))
+	assert.Equal(suite.T(), `"/bucket"`, ExecCmdInCli("cd", "/", "bucket"))
+	assert.Equal(suite.T(), []string{"key ", "subbucket "}, complete("get "))
+	assert.Equal(suite.T(), DbPath+":/bucket> ", prompt())
+
+	defer setEncoding(encodingRaw)
+	setEncoding(encodingHex)
+	assert.Equal(suite.T(), `"/6275636b6574/7375626275636b6574"`, ExecCmdInCli("cd", "7375626275636b6574"))
+	assert.Equal(suite.T(), `1) "6b6579"`, ExecCmdInCli("ls"))
+}
+
+func (suite *CmdSuite) TestPath() {
+	defer func() { CurBucket = []string{} }()
+	assert.Equal(suite.T(), "true", ExecCmdInCli("set", "/bucket/sub bucket:key", "value"))
+	assert.Equal(suite.T(), `"value"`, ExecCmdInCli("get", "bucket", "sub bucket", "key"))
+	assert.Equal(suite.T(), `"value"`, ExecCmdInCli("get", "/bucket/sub bucket:key"))
+	assert.Equal(suite.T(), `1) "key"`, ExecCmdInCli("keys", "/bucket/sub bucket", "*"))
+	assert.Equal(suite.T(), `1) "sub bucket"`, ExecCmdInCli("buckets", "/bucket", "*"))
+	assert.Equal(suite.T(), "true", ExecCmdInCli("exists", "/bucket/sub bucket"))
+	assert.Equal(suite.T(), "1) \"\"\n2) 1) \"key\"\n   2) \"value\"", ExecCmdInCli("range", "-limit", "1", "/bucket/sub bucket"))
+
+	// names starting with the separator are escaped with raw:
+	assert.Equal(suite.T(), "true", ExecCmdInCli("set", "raw:/odd", "key", "value"))
+	assert.Equal(suite.T(), `"value"`, ExecCmdInCli("get", `/\/odd:key`))
+	assert.Equal(suite.T(), `"value"`, ExecCmdInCli("get", "raw:/odd", "key"))
+	res, _ := execCmd("mget", "raw:/odd", "key")
+	assert.Equal(suite.T(), []interface{}{"value"}, res)
+	assert.Equal(suite.T(), "true", ExecCmdInCli("del", "raw:/odd"))
+
+	// a lone pattern is not a path
+	assert.Equal(suite.T(), "true", ExecCmdInCli("set", "raw:/tmp", "home", "/home/alice"))
+	assert.Equal(suite.T(), "true", ExecCmdInCli("set", "raw:/var", "key", "value"))
+	assert.Equal(suite.T(), `1) "/tmp"`, ExecCmdInCli("buckets", "/t*"))
+	assert.Equal(suite.T(), `1) "/\/tmp:home"`, ExecCmdInCli("search", "/home/alice"))
+	assert.Equal(suite.T(), "1", ExecCmdInCli("delglob", "/tmp*"))
+	assert.Equal(suite.T(), "1", ExecCmdInCli("delglob", "/*"))
+	assert.Equal(suite.T(), "true", ExecCmdInCli("exists", "bucket"))
+
+	// names with separators
+	assert.Equal(suite.T(), "true", ExecCmdInCli("set", `/a\/b:c:d/e`, "value"))
+	assert.Equal(suite.T(), `1) "c:d/e"`, ExecCmdInCli("keys", "a/b", "*"))
+	assert.Equal(suite.T(), `"/a\/b"`, ExecCmdInCli("cd", `/a\/b`))
+
+	// absolute paths ignore the current bucket
+	assert.Equal(suite.T(), `"value"`, ExecCmdInCli("get", "/bucket/sub bucket:key"))
+	assert.Equal(suite.T(), `"/bucket/sub bucket"`, ExecCmdInCli("cd", "/bucket/sub bucket"))
+	assert.Equal(suite.T(), "ERR '/bucket:key' is not a bucket path", ExecCmdInCli("cd", "/bucket:key"))
+	assert.Equal(suite.T(), "true", ExecCmdInCli("del", "/bucket/sub bucket:key"))
+	assert.Equal(suite.T(), "false", ExecCmdInCli("exists", "key"))
+	assert.Equal(suite.T(), `"/"`, ExecCmdInCli("cd", "/"))
+
+	defer setEncoding(encodingRaw)
+	setEncoding(encodingHex)
+	assert.Equal(suite.T(), "true", ExecCmdInCli("set", "/6275636b6574:6b6579", "76616c7565"))
+	assert.Equal(suite.T(), `"76616c7565"`, ExecCmdInCli("get", "6275636b6574", "6b6579"))
+	assert.Equal(suite.T(), "ERR invalid path '/bucket\\': nothing to escape at the end", ExecCmdInCli("get", `/bucket\`))
+}
+
+func (suite *CmdSuite) TestCopyAndMove() {
+	defer func() { CurBucket = []string{} }()
+	ExecCmdInCli("set", "/a/b:key", "value")
+	ExecCmdInCli("set", "/a:key2", "value2")
+	DB.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("a")).SetSequence(7)
+	})
+
+	assert.Equal(suite.T(), "true", ExecCmdInCli("copy", "/a", "/c"))
+	assert.Equal(suite.T(), ExecCmdInCli("tree", "a", "-values"), ExecCmdInCli("tree", "c", "-values"))
+	DB.View(func(tx *bolt.Tx) error {
+		assert.Equal(suite.T(), uint64(7), tx.Bucket([]byte("c")).Sequence())
+		return nil
+	})
+	assert.Equal(suite.T(), "ERR /c already exists", ExecCmdInCli("copy", "/a", "/c"))
+	assert.Equal(suite.T(), "ERR keys can't be put outside of buckets", ExecCmdInCli("copy", "/a:key2", "/c"))
+	assert.Equal(suite.T(), "true", ExecCmdInCli("copy", "/a:key2", "/c/b:key", "-overwrite"))
+	assert.Equal(suite.T(), `"value2"`, ExecCmdInCli("get", "/c/b:key"))
+	assert.Equal(suite.T(), "true", ExecCmdInCli("copy", "-overwrite", "/a/b", "/c:key2"))
+	assert.Equal(suite.T(), `1) "b"`+"\n"+`2) "key2"`, ExecCmdInCli("buckets", "c", "*"))
+
+	assert.Equal(suite.T(), "true", ExecCmdInCli("move", "/a/b", "/d/e"))
+	assert.Equal(suite.T(), "false", ExecCmdInCli("exists", "a", "b"))
+	assert.Equal(suite.T(), `"value"`, ExecCmdInCli("get", "/d/e:key"))
+	assert.Equal(suite.T(), "true", ExecCmdInCli("rename", "/d/e", "f"))
+	assert.Equal(suite.T(), `1) "f"`, ExecCmdInCli("buckets", "d", "*"))
+
+	// relative to the current bucket
+	ExecCmdInCli("cd", "a")
+	assert.Equal(suite.T(), "true", ExecCmdInCli("rename", "key2", "key3"))
+	assert.Equal(suite.T(), "true", ExecCmdInCli("move", "key3", "/d:key"))
+	assert.Equal(suite.T(), `"value2"`, ExecCmdInCli("get", "/d:key"))
+	assert.Equal(suite.T(), "ERR no such key or bucket: /a/key3", ExecCmdInCli("copy", "key3", "key4"))
+	assert.Equal(suite.T(), "ERR '/a' and '/a/b' overlap", ExecCmdInCli("copy", "/a", "b"))
+	assert.Equal(suite.T(), "ERR the source and the destination are the same", ExecCmdInCli("move", "/d:key", "/d/key"))
+	assert.Equal(suite.T(), "ERR the root can't be copied or moved", ExecCmdInCli("move", "/", "/e"))
+	assert.Equal(suite.T(), "ERR wrong number of arguments for 'rename' command", ExecCmdInCli("rename", "key"))
+
+	// the paths are resolved when the command is queued
+	assert.Equal(suite.T(), "true", ExecCmdInCli("multi"))
+	assert.Equal(suite.T(), "QUEUED", ExecCmdInCli("cd", "/d"))
+	assert.Equal(suite.T(), "QUEUED", ExecCmdInCli("copy", "/d:key", "key5"))
+	assert.Equal(suite.T(), "1) \"/d\"\n2) true", ExecCmdInCli("exec"))
+	assert.Equal(suite.T(), `"value2"`, ExecCmdInCli("get", "/a:key5"))
+	assert.Equal(suite.T(), "false", ExecCmdInCli("exists", "/d:key5"))
+
+	// the arguments are decoded like those of the other commands
+	defer setEncoding(encodingRaw)
+	setEncoding(encodingHex)
+	assert.Equal(suite.T(), "true", ExecCmdInCli("rename", "/61:6b657935", "6b657936"))
+	assert.Equal(suite.T(), "true", ExecCmdInCli("copy", "6b6579", "/61:6b657937"))
+	setEncoding(encodingRaw)
+	assert.Equal(suite.T(), `"value2"`, ExecCmdInCli("get", "/a:key6"))
+	assert.Equal(suite.T(), `"value2"`, ExecCmdInCli("get", "/a:key7"))
+}
+
+func (suite *CmdSuite) TestCompact() {
+	value := strings.Repeat("v", 1024)
+	DB.Update(func(tx *bolt.Tx) error {
+		b, _ := tx.CreateBucket([]byte("bucket"))
+		for i := 0; i < 1000; i++ {
+			b.Put([]byte(fmt.Sprintf("key%d", i)), []byte(value))
+		}
+		return nil
+	})
+	ExecCmdInCli("delglob", "bucket", "key?*")
+	ExecCmdInCli("set", "bucket", "key", "value")
+
+	dst := suite.dbPath + ".compacted"
+	defer os.Remove(dst)
+	res, err := execCmd("compact", dst, "-txsize", "1024")
+	assert.Nil(suite.T(), err)
+	sizes := res.(map[string]interface{})
+	assert.True(suite.T(), sizes["after"].(int64) < sizes["before"].(int64))
+	assert.Equal(suite.T(), "ERR "+dst+" already exists", ExecCmdInCli("compact", dst))
+	assert.Equal(suite.T(), "ERR value of option '-txsize' should be a non-negative integer",
+		ExecCmdInCli("compact", dst, "-txsize", "-1"))
+
+	compacted, _ := bolt.Open(dst, 0600, nil)
+	compacted.View(func(tx *bolt.Tx) error {
+		assert.Equal(suite.T(), []byte("value"), tx.Bucket([]byte("bucket")).Get([]byte("key")))
+		return nil
+	})
+	compacted.Close()
+	os.Remove(dst)
+
+	res, err = execCmd("compact", dst, "-swap")
+	assert.Nil(suite.T(), err)
+	fi, _ := os.Stat(suite.dbPath)
+	assert.Equal(suite.T(), res.(map[string]interface{})["after"], fi.Size())
+	_, err = os.Stat(dst)
+	assert.True(suite.T(), os.IsNotExist(err))
+	assert.Equal(suite.T(), `"value"`, ExecCmdInCli("get", "bucket", "key"))
+
+	// the original file is reopened if the new file can't be opened
+	ioutil.WriteFile(dst, []byte("not a database"), 0600)
+	err = swapDB(dst)
+	assert.True(suite.T(), strings.HasPrefix(err.Error(), "failed to open the compacted file, the original file is kept: "))
+	assert.Equal(suite.T(), `"value"`, ExecCmdInCli("get", "bucket", "key"))
+	data, _ := ioutil.ReadFile(dst)
+	assert.Equal(suite.T(), "not a database", string(data))
+	matches, _ := filepath.Glob(suite.dbPath + ".orig*")
+	assert.Empty(suite.T(), matches)
+	os.Remove(dst)
+
+	ExecCmdInCli("multi")
+	ExecCmdInCli("compact", dst)
+	assert.Equal(suite.T(), "ERR command 1 (compact) failed, transaction is rolled back: can't compact the database in a transaction",
+		ExecCmdInCli("exec"))
+}
+
+func (suite *CmdSuite) TestBackup() {
+	ExecCmdInCli("set", "bucket", "key", "value")
+	dst := suite.dbPath + ".backup"
+	defer os.Remove(dst)
+	defer os.Remove(dst + ".sha256")
+
+	res, err := execCmd("backup", dst, "-checksum")
+	assert.Nil(suite.T(), err)
+	info := res.(map[string]interface{})
+	fi, _ := os.Stat(dst)
+	assert.Equal(suite.T(), fi.Size(), info["size"])
+	data, _ := ioutil.ReadFile(dst)
+	sum := sha256.Sum256(data)
+	assert.Equal(suite.T(), hex.EncodeToString(sum[:]), info["sha256"])
+	line, _ := ioutil.ReadFile(dst + ".sha256")
+	assert.Equal(suite.T(), hex.EncodeToString(sum[:])+"  "+filepath.Base(dst)+"\n", string(line))
+
+	snapshot, _ := bolt.Open(dst, 0600, nil)
+	snapshot.View(func(tx *bolt.Tx) error {
+		assert.Equal(suite.T(), []byte("value"), tx.Bucket([]byte("bucket")).Get([]byte("key")))
+		return nil
+	})
+	snapshot.Close()
+
+	assert.Equal(suite.T(), "ERR "+dst+" already exists", ExecCmdInCli("backup", dst))
+	os.Remove(dst)
+	assert.Equal(suite.T(), "ERR "+dst+".sha256 already exists", ExecCmdInCli("backup", dst, "-checksum"))
+	_, err = os.Stat(dst)
+	assert.True(suite.T(), os.IsNotExist(err))
+	res, err = execCmd("backup", dst, "-gzip")
+	assert.Nil(suite.T(), err)
+	f, _ := os.Open(dst)
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	assert.Nil(suite.T(), err)
+	unzipped, _ := ioutil.ReadAll(zr)
+	assert.Equal(suite.T(), data, unzipped)
+	assert.True(suite.T(), res.(map[string]interface{})["size"].(int64) < int64(len(data)))
+
+	assert.Equal(suite.T(), "ERR wrong number of arguments for 'backup' command", ExecCmdInCli("backup"))
+
+	// the database itself is never overwritten
+	fi, _ = os.Stat(suite.dbPath)
+	assert.Equal(suite.T(), "ERR can't back up the database to itself", ExecCmdInCli("backup", suite.dbPath))
+	assert.Equal(suite.T(), "ERR can't back up the database to itself",
+		ExecCmdInCli("backup", filepath.Join(filepath.Dir(suite.dbPath), ".", filepath.Base(suite.dbPath))))
+	after, _ := os.Stat(suite.dbPath)
+	assert.Equal(suite.T(), fi.Size(), after.Size())
+	assert.Equal(suite.T(), `"value"`, ExecCmdInCli("get", "bucket", "key"))
+
+	// a snapshot in a transaction would miss the queued writes
+	os.Remove(dst)
+	ExecCmdInCli("multi")
+	ExecCmdInCli("set", "bucket", "key", "new")
+	ExecCmdInCli("backup", dst)
+	assert.Equal(suite.T(), "ERR command 2 (backup) failed, transaction is rolled back: can't back up the database in a transaction",
+		ExecCmdInCli("exec"))
+	_, err = os.Stat(dst)
+	assert.True(suite.T(), os.IsNotExist(err))
+	matches, _ := filepath.Glob(dst + ".tmp*")
+	assert.Empty(suite.T(), matches)
+}
+
+func (suite *CmdSuite) TestCheck() {
+	ExecCmdInCli("set", "bucket", "key", "value")
+	ExecCmdInCli("set", "bucket", "subbucket", "key", "value")
+	ExecCmdInCli("set", "bucket2", "key", "")
+	assert.Equal(suite.T(), "buckets) 3\nkeys) 3\nproblems) 0", ExecCmdInCli("check"))
+	assert.Equal(suite.T(), "ERR wrong number of arguments for 'check' command", ExecCmdInCli("check", "bucket"))
+
+	// problems are included in the error unless they are written to the progress output
+	c := &checker{}
+	c.buckets = 1
+	c.report("%s: key '%s' is out of order", "/bucket", "key")
+	assert.Equal(suite.T(), []string{"/bucket: key 'key' is out of order"}, c.problems)
+
+	defer func() { progressOutput = nil }()
+	var buf bytes.Buffer
+	progressOutput = &buf
+	c = &checker{}
+	c.report("page %d: unreachable unfreed", 3)
+	c.report("page %d: unreachable unfreed", 4)
+	assert.Equal(suite.T(), "page 3: unreachable unfreed\npage 4: unreachable unfreed\n", buf.String())
+	assert.Equal(suite.T(), int64(2), c.count)
+	assert.Nil(suite.T(), c.problems)
+
+	buf.Reset()
+	assert.True(suite.T(), RunCmd(&buf, []string{"check"}))
+	assert.Equal(suite.T(), "buckets) 3\nkeys) 3\nproblems) 0\n", buf.String())
+
+	// clobber a key in the leaf pages of a bucket, so that the keys are out of order
+	DB.Update(func(tx *bolt.Tx) error {
+		b, _ := tx.CreateBucket([]byte("large"))
+		for i := 0; i < 100; i++ {
+			b.Put([]byte(fmt.Sprintf("key_%03d", i)), bytes.Repeat([]byte("v"), 100))
+		}
+		return nil
+	})
+	DB.Close()
+	data, _ := ioutil.ReadFile(suite.dbPath)
+	assert.True(suite.T(), bytes.Contains(data, []byte("key_050")))
+	ioutil.WriteFile(suite.dbPath, bytes.Replace(data, []byte("key_050"), []byte("key_000"), -1), 0600)
+	initDB(suite.dbPath)
+
+	buf.Reset()
+	assert.False(suite.T(), RunCmd(&buf, []string{"check"}))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(suite.T(), 3, len(lines))
+	// the page check of bolt finds it as well
+	assert.Contains(suite.T(), lines[0], "key[2]=(hex)6b65795f303030 on leaf page")
+	assert.Equal(suite.T(), []string{
+		"/large: key 'key_000' is out of order",
+		"ERR found 2 problems in 4 buckets and 103 keys",
+	}, lines[1:])
+
+	// without the progress output, the problems are in the error
+	progressOutput = nil
+	_, err := execCmd("check")
+	assert.NotNil(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "\n/large: key 'key_000' is out of order")
+}
+
+func (suite *CmdSuite) TestBucketStats() {
+	defer func() { CurBucket = []string{} }()
+	ExecCmdInCli("set", "/a:key", "value")
+	ExecCmdInCli("set", "/a/b:key", "value")
+	for i := 0; i < 10; i++ {
+		ExecCmdInCli("set", "/c:key"+strconv.Itoa(i), strings.Repeat("v", 100))
+	}
+	for i := 0; i < 3; i++ {
+		ExecCmdInCli("set", "/d:key"+strconv.Itoa(i), "value")
+	}
+	paths := func(args ...string) []string {
+		res, err := execCmd("bucketstats", args...)
+		assert.Nil(suite.T(), err)
+		names := []string{}
+		for _, item := range res.([]interface{}) {
+			names = append(names, item.([]interface{})[0].(string))
+		}
+		return names
+	}
+	assert.Equal(suite.T(), []string{"/a", "/c", "/d"}, paths())
+	assert.Equal(suite.T(), []string{"/a", "/a/b", "/c", "/d"}, paths("-recursive"))
+	assert.Equal(suite.T(), []string{"/c", "/a", "/d", "/a/b"}, paths("-recursive", "-sort", "size"))
+	assert.Equal(suite.T(), []string{"/c"}, paths("-sort", "KEYS", "-limit", "1"))
+	assert.Equal(suite.T(), []string{"/a/b"}, paths("/a/b"))
+	assert.Equal(suite.T(), []string{}, paths("/nonexistent"))
+	ExecCmdInCli("cd", "a")
+	assert.Equal(suite.T(), []string{"/a", "/a/b"}, paths("-recursive"))
+
+	res, _ := execCmd("bucketstats", "b")
+	stat := res.([]interface{})[0].([]interface{})[1].(map[string]interface{})
+	assert.Equal(suite.T(), int64(1), stat["KeyN"])
+	assert.Equal(suite.T(), int64(1), stat["BucketN"])
+	// an inline bucket has no pages
+	assert.Equal(suite.T(), int64(0), stat["LeafInuse"])
+	assert.True(suite.T(), stat["InlineBucketInuse"].(int64) > 0)
+	assert.Equal(suite.T(), stat["InlineBucketInuse"], stat["Inuse"])
+	assert.True(suite.T(), strings.HasPrefix(ExecCmdInCli("bucketstats", "b"), "1) 1) \"/a/b\"\n   2) Alloc) "))
+	assert.Equal(suite.T(), "ERR unknown order 'depth', available orders: name, size, keys",
+		ExecCmdInCli("bucketstats", "-sort", "depth"))
+}
+
+func (suite *CmdSuite) TestPages() {
+	ExecCmdInCli("set", "bucket", "key", "value")
+	ExecCmdInCli("set", "bucket", "subbucket", "key", "value")
+
+	res, err := execCmd("info")
+	assert.Nil(suite.T(), err)
+	info := res.(map[string]interface{})
+	assert.Equal(suite.T(), int64(os.Getpagesize()), info["PageSize"])
+	for _, name := range []string{"Meta0", "Meta1"} {
+		meta := info[name].(map[string]interface{})
+		assert.Equal(suite.T(), true, meta["Valid"])
+		assert.Equal(suite.T(), "0xed0cdaed", meta["Magic"])
+	}
+
+	res, err = execCmd("pages")
+	assert.Nil(suite.T(), err)
+	leaf := int64(-1)
+	for i, p := range res.([]interface{}) {
+		p := p.(map[string]interface{})
+		if i < 2 {
+			assert.Equal(suite.T(), "meta", p["Type"])
+		}
+		if p["Type"] == "leaf" {
+			leaf = p["ID"].(int64)
+		}
+	}
+	assert.True(suite.T(), leaf > 0)
+	res, _ = execCmd("pages", "-start", "1", "-limit", "1")
+	assert.Equal(suite.T(), 1, len(res.([]interface{})))
+	assert.Equal(suite.T(), int64(1), res.([]interface{})[0].(map[string]interface{})["ID"])
+
+	// the root bucket is the only leaf page, the others are inlined
+	assert.Equal(suite.T(), "Count) 1\nID) "+strconv.FormatInt(leaf, 10)+"\nKeys)\n    1) \"bucket/\"\nOverflowCount) 0\nType) \"leaf\"",
+		ExecCmdInCli("page", strconv.FormatInt(leaf, 10)))
+	res, err = execCmd("page", "0")
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), true, res.(map[string]interface{})["Meta"].(map[string]interface{})["Valid"])
+	assert.Equal(suite.T(), "ERR invalid page id 'x'", ExecCmdInCli("page", "x"))
+	assert.True(suite.T(), strings.HasPrefix(ExecCmdInCli("page", "1000"), "ERR page 1000 is out of range"))
+
+	// the freelist is not loaded by bolt in read-only mode
+	DB.Close()
+	*readOnly = true
+	defer func() { *readOnly = false }()
+	initDB(suite.dbPath)
+	res, err = execCmd("pages", "-limit", "1")
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "meta", res.([]interface{})[0].(map[string]interface{})["Type"])
+
+	// a corrupted overflow count is rejected before the pages are read
+	f, _ := os.OpenFile(suite.dbPath, os.O_WRONLY, 0)
+	overflow := make([]byte, 4)
+	nativeEndian.PutUint32(overflow, 0xfffffff0)
+	f.WriteAt(overflow, leaf*int64(os.Getpagesize())+12)
+	f.Close()
+	assert.True(suite.T(), strings.HasPrefix(ExecCmdInCli("page", strconv.FormatInt(leaf, 10)),
+		"ERR page "+strconv.FormatInt(leaf, 10)+" is corrupted: its 4294967280 overflow pages exceed"))
+}
+
+func (suite *CmdSuite) TestDecoder() {
+	defer func() {
+		CurBucket = []string{}
+		decoderBindings = []decoderBinding{}
+		setEncoding(encodingRaw)
+	}()
+	ExecCmdInCli("set", "bucket", "user", `{"name":"bolt","tags":["a","b"],"age":3}`)
+	ExecCmdInCli("set", "bucket", "n", "0x000000000000002a")
+	ExecCmdInCli("set", "bucket", "msg", "0x82a161c3a162c0")
+
+	assert.Equal(suite.T(), "{\n  \"age\": 3,\n  \"name\": \"bolt\",\n  \"tags\": [\n    \"a\",\n    \"b\"\n  ]\n}",
+		ExecCmdInCli("get", "-as", "json", "bucket", "user"))
+	assert.Equal(suite.T(), "42", ExecCmdInCli("get", "bucket", "n", "-as", "u64be"))
+	assert.Equal(suite.T(), "{\n  \"a\": true,\n  \"b\": null\n}", ExecCmdInCli("get", "-as", "msgpack", "/bucket:msg"))
+	assert.Equal(suite.T(), `""`, ExecCmdInCli("get", "-as", "json", "bucket", "non-exist"))
+	assert.Equal(suite.T(), "ERR can't decode the value of key 'n': invalid character '\\x00' looking for beginning of value",
+		ExecCmdInCli("get", "-as", "json", "bucket", "n"))
+	assert.True(suite.T(), strings.HasPrefix(ExecCmdInCli("get", "-as", "yaml", "bucket", "n"),
+		"ERR unknown decoder 'yaml', available decoders: "))
+
+	// the bound decoder skips the values which can't be decoded
+	assert.Equal(suite.T(), `"none"`, ExecCmdInCli("decoder", "bucket"))
+	assert.Equal(suite.T(), `"json"`, ExecCmdInCli("decoder", "/bucket", "JSON"))
+	assert.Equal(suite.T(), `/bucket) "json"`, ExecCmdInCli("decoder"))
+	res, err := execCmd("get", "bucket", "user")
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), int64(3), res.(Decoded).Value.(map[string]interface{})["age"])
+	assert.Equal(suite.T(), "\"\x00\x00\x00\x00\x00\x00\x00*\"", ExecCmdInCli("get", "bucket", "n"))
+	assert.Equal(suite.T(), "42", ExecCmdInCli("get", "-as", "u64be", "bucket", "n"))
+
+	// the keys of decoded values are encoded, but the decoded values are not
+	setEncoding(encodingHex)
+	res, err = execCmd("keyvalues", "6275636b6574", "6e", "-as", "u64be")
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), map[string]interface{}{"6e": Decoded{int64(42)}}, res)
+	_, err = execCmd("keyvalues", "6275636b6574", "raw:*", "-as", "u64be")
+	assert.Equal(suite.T(), "can't decode the value of key '6d7367': expected 8 bytes, got 7 bytes", err.Error())
+	setEncoding(encodingRaw)
+
+	assert.Equal(suite.T(), "1) \"\"\n2) 1) \"msg\"\n   2) \"\x82\xa1a\xc3\xa1b\xc0\"\n   3) \"n\"\n   4) \"\x00\x00\x00\x00\x00\x00\x00*\"",
+		ExecCmdInCli("range", "bucket", "-end", "u"))
+	assert.Equal(suite.T(), "1) \"n\"\n2) 1) \"msg\"\n   2) {\n        \"a\": true,\n        \"b\": null\n      }",
+		ExecCmdInCli("range", "bucket", "-limit", "1", "-as", "msgpack"))
+	assert.Equal(suite.T(), "msg) \"\x82\xa1a\xc3\xa1b\xc0\"\nuser)\n    {\n      \"age\": 3,\n      \"name\": \"bolt\",\n      \"tags\": [\n        \"a\",\n        \"b\"\n      ]\n    }",
+		ExecCmdInCli("keyvalues", "bucket", "[mu]*"))
+
+	assert.Equal(suite.T(), `"none"`, ExecCmdInCli("decoder", "bucket", "none"))
+	assert.Equal(suite.T(), "", ExecCmdInCli("decoder"))
+	assert.Equal(suite.T(), "ERR decoders can only be bound to buckets", ExecCmdInCli("decoder", "/"))
+}
+
+func (suite *CmdSuite) TestCounter() {
+	defer setEncoding(encodingRaw)
+	assert.Equal(suite.T(), "1", ExecCmdInCli("incr", "bucket", "counter"))
+	assert.Equal(suite.T(), "11", ExecCmdInCli("incrby", "bucket", "counter", "10"))
+	assert.Equal(suite.T(), "10", ExecCmdInCli("decr", "/bucket:counter"))
+	assert.Equal(suite.T(), "-5", ExecCmdInCli("incrby", "bucket", "counter", "-15"))
+	assert.Equal(suite.T(), `"-5"`, ExecCmdInCli("get", "bucket", "counter"))
+	assert.Equal(suite.T(), "ERR increment 'x' is not an integer or out of range",
+		ExecCmdInCli("incrby", "bucket", "counter", "x"))
+	assert.Equal(suite.T(), "ERR wrong number of arguments for 'incrby' command", ExecCmdInCli("incrby", "bucket", "1"))
+	assert.Equal(suite.T(), "ERR wrong number of arguments for 'incr' command", ExecCmdInCli("incr", "bucket"))
+
+	ExecCmdInCli("set", "bucket", "key", "value")
+	assert.Equal(suite.T(), "ERR value is not a decimal integer or out of range", ExecCmdInCli("incr", "bucket", "key"))
+	ExecCmdInCli("set", "bucket", "max", "9223372036854775807")
+	assert.Equal(suite.T(), "ERR increment or decrement would overflow", ExecCmdInCli("incr", "bucket", "max"))
+	ExecCmdInCli("set", "bucket", "sub", "key", "value")
+	assert.Equal(suite.T(), "ERR incompatible value", ExecCmdInCli("incr", "bucket", "sub"))
+
+	// binary counters, and the increment is not decoded
+	setEncoding(encodingHex)
+	assert.Equal(suite.T(), "2", ExecCmdInCli("incrby", "6275636b6574", "7531", "2", "-format", "u64be"))
+	assert.Equal(suite.T(), `"0000000000000002"`, ExecCmdInCli("get", "6275636b6574", "7531"))
+	assert.Equal(suite.T(), "1", ExecCmdInCli("incr", "6275636b6574", "7532", "-format", "U64LE"))
+	assert.Equal(suite.T(), `"0100000000000000"`, ExecCmdInCli("get", "6275636b6574", "7532"))
+	setEncoding(encodingRaw)
+	assert.Equal(suite.T(), "ERR increment or decrement would overflow",
+		ExecCmdInCli("incrby", "bucket", "u1", "-3", "-format", "u64be"))
+	assert.Equal(suite.T(), "ERR value is not a 64-bit integer, it has 5 bytes",
+		ExecCmdInCli("incr", "bucket", "key", "-format", "u64le"))
+	assert.Equal(suite.T(), "ERR unknown format 'int', available formats: decimal, u64be, u64le",
+		ExecCmdInCli("incr", "bucket", "key", "-format", "int"))
+	ExecCmdInCli("set", "bucket", "umax", "0xfffffffffffffffe")
+	assert.Equal(suite.T(), "18446744073709551615", ExecCmdInCli("incr", "bucket", "umax", "-format", "u64be"))
+
+	assert.Equal(suite.T(), "1", ExecCmdInCli("nextseq", "seq"))
+	assert.Equal(suite.T(), "2", ExecCmdInCli("nextseq", "/seq"))
+	assert.Equal(suite.T(), "true", ExecCmdInCli("setseq", "seq", "100"))
+	assert.Equal(suite.T(), "101", ExecCmdInCli("nextseq", "seq"))
+	assert.Equal(suite.T(), "ERR sequence '-1' is not an unsigned integer or out of range",
+		ExecCmdInCli("setseq", "seq", "-1"))
+	assert.Equal(suite.T(), "ERR wrong number of arguments for 'nextseq' command", ExecCmdInCli("nextseq"))
+}
+
+func (suite *CmdSuite) TestConditionalSet() {
+	assert.Equal(suite.T(), "true", ExecCmdInCli("setnx", "bucket", "key", "value"))
+	assert.Equal(suite.T(), "false", ExecCmdInCli("setnx", "/bucket:key", "other"))
+	assert.Equal(suite.T(), `"value"`, ExecCmdInCli("get", "bucket", "key"))
+	ExecCmdInCli("set", "bucket", "sub", "key", "value")
+	assert.Equal(suite.T(), "false", ExecCmdInCli("setnx", "bucket", "sub", "value"))
+	assert.Equal(suite.T(), "ERR wrong number of arguments for 'setnx' command", ExecCmdInCli("setnx", "bucket", "key"))
+
+	assert.Equal(suite.T(), "false", ExecCmdInCli("cas", "bucket", "key", "other", "new"))
+	assert.Equal(suite.T(), `"value"`, ExecCmdInCli("get", "bucket", "key"))
+	assert.Equal(suite.T(), "true", ExecCmdInCli("cas", "bucket", "key", "value", "new"))
+	assert.Equal(suite.T(), `"new"`, ExecCmdInCli("get", "bucket", "key"))
+	assert.Equal(suite.T(), "false", ExecCmdInCli("cas", "bucket", "non-exist", "", "new"))
+	assert.Equal(suite.T(), "false", ExecCmdInCli("cas", "non-exist", "key", "new", "value"))
+	assert.Equal(suite.T(), "ERR wrong number of arguments for 'cas' command", ExecCmdInCli("cas", "bucket", "key", "new"))
+
+	assert.Equal(suite.T(), `"new"`, ExecCmdInCli("getset", "bucket", "key", "newer"))
+	assert.Equal(suite.T(), `"newer"`, ExecCmdInCli("get", "bucket", "key"))
+	assert.Equal(suite.T(), "(nil)", ExecCmdInCli("getset", "/bucket2:key", ""))
+	assert.Equal(suite.T(), `""`, ExecCmdInCli("getset", "/bucket2:key", "value"))
+	assert.Equal(suite.T(), `"value"`, ExecCmdInCli("get", "bucket2", "key"))
+	defer setOutputFormat(outputText)
+	setOutputFormat(outputNDJSON)
+	assert.Equal(suite.T(), `{"ok":true,"result":null}`, ExecCmdInCli("getset", "/bucket3:key", "value"))
+	setOutputFormat(outputText)
+	assert.Equal(suite.T(), "ERR incompatible value", ExecCmdInCli("getset", "bucket", "sub", "value"))
+}
+
+func (suite *CmdSuite) TestMultiKeys() {
+	defer func() {
+		CurBucket = []string{}
+		setEncoding(encodingRaw)
+	}()
+	assert.Equal(suite.T(), "true", ExecCmdInCli("mset", "bucket", "k1", "v1", "k2", "v2"))
+	assert.Equal(suite.T(), "1) \"v1\"\n2) \"\"\n3) \"v2\"", ExecCmdInCli("mget", "bucket", "k1", "k3", "k2"))
+	assert.Equal(suite.T(), "ERR wrong number of arguments for 'mset' command", ExecCmdInCli("mset", "bucket", "k1"))
+	assert.Equal(suite.T(), "ERR wrong number of arguments for 'mget' command", ExecCmdInCli("mget", "bucket"))
+
+	assert.Equal(suite.T(), "ERR wrong number of arguments for 'mset' command",
+		ExecCmdInCli("mset", "bucket", "k1", "v1", "k2", "v2", "k3"))
+	assert.Equal(suite.T(), "ERR bucket name required", ExecCmdInCli("mset", "/", "k1", "v1"))
+	assert.Equal(suite.T(), "ERR invalid bucket path '/bucket:k1': it should not contain a key",
+		ExecCmdInCli("mget", "/bucket:k1", "k2"))
+	// no bucket is created for the keys
+	assert.Equal(suite.T(), "1) \"k1\"\n2) \"k2\"", ExecCmdInCli("keys", "bucket", "*"))
+
+	assert.Equal(suite.T(), "true", ExecCmdInCli("mset", "/bucket/sub", "k1", "v1", "k2", "v2", "k3", "v3"))
+	assert.Equal(suite.T(), `"v1"`, ExecCmdInCli("get", "bucket", "sub", "k1"))
+	assert.Equal(suite.T(), "true", ExecCmdInCli("mset", "/bucket/sub/subsub", "k1", "v1"))
+	res, err := execCmd("mget", "/bucket/sub", "k1", "k2", "k3", "subsub")
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []interface{}{"v1", "v2", "v3", ""}, res)
+	res, _ = execCmd("mget", "/bucket/sub/subsub", "k1")
+	assert.Equal(suite.T(), []interface{}{"v1"}, res)
+	// the other arguments are always keys
+	res, _ = execCmd("mget", "bucket", "sub", "k1")
+	assert.Equal(suite.T(), []interface{}{"", "v1"}, res)
+	ExecCmdInCli("cd", "bucket")
+	res, _ = execCmd("mget", "sub", "k1", "k2")
+	assert.Equal(suite.T(), []interface{}{"v1", "v2"}, res)
+	assert.Equal(suite.T(), "true", ExecCmdInCli("mset", "sub", "k4", "v4"))
+	assert.Equal(suite.T(), `"v4"`, ExecCmdInCli("get", "/bucket/sub:k4"))
+	ExecCmdInCli("cd", "/")
+	res, _ = execCmd("mget", "non-exist", "k1")
+	assert.Equal(suite.T(), []interface{}{""}, res)
+
+	ExecCmdInCli("mset", "numbers", "a", "0x0000000000000001", "b", "0x0000000000000002")
+	assert.Equal(suite.T(), "1) 1\n2) 2\n3) \"\"", ExecCmdInCli("mget", "numbers", "a", "b", "c", "-as", "u64be"))
+	setEncoding(encodingHex)
+	res, _ = execCmd("mget", "6275636b6574", "6b31", "6b32")
+	assert.Equal(suite.T(), []interface{}{"7631", "7632"}, res)
+	res, _ = execCmd("mget", "/6275636b6574/737562", "6b31")
+	assert.Equal(suite.T(), []interface{}{"7631"}, res)
+}
+
+func (suite *CmdSuite) TestInspect() {
+	defer setEncoding(encodingRaw)
+	ExecCmdInCli("set", "bucket", "key", "value")
+	ExecCmdInCli("set", "bucket", "sub", "big", strings.Repeat("v", 5000))
+	ExecCmdInCli("set", "other", "medium", strings.Repeat("v", 100))
+	ExecCmdInCli("nextseq", "bucket")
+
+	assert.Equal(suite.T(), "5", ExecCmdInCli("strlen", "bucket", "key"))
+	assert.Equal(suite.T(), "5000", ExecCmdInCli("strlen", "/bucket/sub:big"))
+	assert.Equal(suite.T(), "0", ExecCmdInCli("strlen", "bucket", "non-exist"))
+	assert.Equal(suite.T(), "ERR incompatible value", ExecCmdInCli("strlen", "bucket", "sub"))
+	assert.Equal(suite.T(), "ERR wrong number of arguments for 'strlen' command", ExecCmdInCli("strlen", "bucket"))
+
+	assert.Equal(suite.T(), `"bucket"`, ExecCmdInCli("type", "bucket"))
+	assert.Equal(suite.T(), `"bucket"`, ExecCmdInCli("type", "bucket", "sub"))
+	assert.Equal(suite.T(), `"key"`, ExecCmdInCli("type", "bucket", "key"))
+	assert.Equal(suite.T(), `"none"`, ExecCmdInCli("type", "bucket", "non-exist"))
+	assert.Equal(suite.T(), `"none"`, ExecCmdInCli("type", "non-exist", "key"))
+
+	assert.Equal(suite.T(), "inline) false\npath) \"/bucket:key\"\nsequence) 1\nsize) 5\ntype) \"key\"",
+		ExecCmdInCli("meta", "bucket", "key"))
+	res, err := execCmd("meta", "/bucket/sub")
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), map[string]interface{}{
+		"inline": false, "path": "/bucket/sub", "sequence": int64(0), "type": "bucket",
+	}, res)
+	res, _ = execCmd("meta", "other")
+	assert.Equal(suite.T(), true, res.(map[string]interface{})["inline"])
+	assert.Equal(suite.T(), "ERR no such key or bucket: /bucket/non-exist", ExecCmdInCli("meta", "bucket", "non-exist"))
+
+	assert.Equal(suite.T(), "1) 1) \"/bucket/sub:big\"\n   2) 5000\n2) 1) \"/other:medium\"\n   2) 100",
+		ExecCmdInCli("bigkeys", "-limit", "2"))
+	res, _ = execCmd("bigkeys", "bucket")
+	assert.Equal(suite.T(), []interface{}{
+		[]interface{}{"/bucket/sub:big", int64(5000)},
+		[]interface{}{"/bucket:key", int64(5)},
+	}, res)
+	assert.Equal(suite.T(), "ERR value of option '-limit' should be a positive integer", ExecCmdInCli("bigkeys", "-limit", "0"))
+
+	setEncoding(encodingHex)
+	assert.Equal(suite.T(), `"key"`, ExecCmdInCli("type", "6275636b6574", "6b6579"))
+	res, _ = execCmd("bigkeys", "6f74686572")
+	assert.Equal(suite.T(), []interface{}{[]interface{}{"/6f74686572:6d656469756d", int64(100)}}, res)
+}
+
+func (suite *CmdSuite) TestSearch() {
+	defer func() {
+		CurBucket = []string{}
+		setEncoding(encodingRaw)
+	}()
+	ExecCmdInCli("set", "users", "alice", `{"email":"alice@example.com"}`)
+	ExecCmdInCli("set", "users", "bob", `{"email":"bob@example.org"}`)
+	ExecCmdInCli("set", "users", "admins", "carol", `{"email":"carol@example.com"}`)
+	ExecCmdInCli("set", "logs", "1", "alice logged in")
+	ExecCmdInCli("set", "logs", "2", "/api/ called")
+
+	assert.Equal(suite.T(), "1) \"/logs:1\"\n2) \"/users:alice\"", ExecCmdInCli("search", "alice"))
+	res, err := execCmd("search", "users", "example.com")
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []string{"/users/admins:carol", "/users:alice"}, res)
+	res, _ = execCmd("search", "/users/admins", "example")
+	assert.Equal(suite.T(), []string{"/users/admins:carol"}, res)
+	res, _ = execCmd("search", "users", "example", "-limit", "2")
+	assert.Equal(suite.T(), []string{"/users/admins:carol", "/users:alice"}, res)
+	res, _ = execCmd("search", "users", "*@example.org*", "-glob")
+	assert.Equal(suite.T(), []string{"/users:bob"}, res)
+	res, _ = execCmd("search", "-regex", `^\{"email":"[a-c]\w+@`)
+	assert.Equal(suite.T(), []string{"/users/admins:carol", "/users:alice", "/users:bob"}, res)
+	ExecCmdInCli("set", "users", "dave", "{}")
+	res, _ = execCmd("search", "users", "dave", "-keys")
+	assert.Equal(suite.T(), []string{"/users:dave"}, res)
+	res, _ = execCmd("search", "users", "dave")
+	assert.Equal(suite.T(), []string{}, res)
+	res, _ = execCmd("search", "raw:/api/")
+	assert.Equal(suite.T(), []string{"/logs:2"}, res)
+	res, _ = execCmd("search", "non-exist", "alice")
+	assert.Equal(suite.T(), []string{}, res)
+
+	ExecCmdInCli("cd", "users")
+	res, _ = execCmd("search", "example.com")
+	assert.Equal(suite.T(), []string{"/users/admins:carol", "/users:alice"}, res)
+	ExecCmdInCli("cd", "/")
+
+	assert.Equal(suite.T(), "ERR -glob and -regex can't be used together", ExecCmdInCli("search", "a", "-glob", "-regex"))
+	assert.True(suite.T(), strings.HasPrefix(ExecCmdInCli("search", "(", "-regex"), "ERR error parsing regexp"))
+	assert.Equal(suite.T(), "ERR wrong number of arguments for 'search' command", ExecCmdInCli("search"))
+
+	setEncoding(encodingHex)
+	res, _ = execCmd("search", "6c6f6773", "6c6f67676564")
+	assert.Equal(suite.T(), []string{"/6c6f6773:31"}, res)
+}
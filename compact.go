@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// defaultCompactTxSize is the default max size of the transactions used by compact
+const defaultCompactTxSize = 65536
+
+func init() {
+	cmdOptions["compact"] = optSpec{
+		"-txsize": optValue,
+		"-swap":   optFlag,
+	}
+}
+
+// swapDB replaces the database file with the file in given path, and reopens the database.
+// If the new file can't be opened, it is moved back to the path, and the original file is reopened.
+func swapDB(path string) error {
+	if err := DB.Close(); err != nil {
+		return err
+	}
+	orig, err := replaceFile(path, DbPath)
+	if err == nil {
+		db, openErr := openDB(DbPath)
+		if openErr == nil {
+			DB = db
+			os.Remove(orig)
+			return nil
+		}
+		err = fmt.Errorf("failed to open the compacted file, the original file is kept: %v", openErr)
+		if os.Rename(DbPath, path) != nil || os.Rename(orig, DbPath) != nil {
+			log.Fatalf("Could not put the original file of %s back, it is kept in %s", DbPath, orig)
+		}
+	}
+	// reopen the original file, as the commands after this one need the database
+	db, openErr := openDB(DbPath)
+	if openErr != nil {
+		log.Fatalf("Could not reopen %s: %v", DbPath, openErr)
+	}
+	DB = db
+	return err
+}
+
+// replaceFile renames the file in src to dst. The original dst is renamed to a new file
+// next to it, whose path is returned, so that it can be put back.
+func replaceFile(src, dst string) (string, error) {
+	f, err := ioutil.TempFile(filepath.Dir(dst), filepath.Base(dst)+".orig")
+	if err != nil {
+		return "", err
+	}
+	orig := f.Name()
+	f.Close()
+	if err = os.Rename(dst, orig); err != nil {
+		os.Remove(orig)
+		return "", err
+	}
+	if err = os.Rename(src, dst); err != nil {
+		os.Rename(orig, dst)
+		return "", err
+	}
+	return orig, nil
+}
+
+func compact(args ...string) (res interface{}, err error) {
+	opts, args, err := parseOptions("compact", args)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) != 1 {
+		return nil, fmt.Errorf("wrong number of arguments for '%s' command", "compact")
+	}
+	txSize, err := intOption(opts, "-txsize", defaultCompactTxSize)
+	if err != nil {
+		return nil, err
+	}
+	_, swap := opts["-swap"]
+	if swap && DB.IsReadOnly() {
+		return nil, errors.New("can't swap the compacted file, the database is opened in read-only mode")
+	}
+	if curTx != nil {
+		return nil, errors.New("can't compact the database in a transaction")
+	}
+
+	dst := args[0]
+	if _, err = os.Stat(dst); err == nil {
+		return nil, fmt.Errorf("%s already exists", dst)
+	}
+	fi, err := os.Stat(DbPath)
+	if err != nil {
+		return nil, err
+	}
+	dstDB, err := bolt.Open(dst, fi.Mode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	err = bolt.Compact(dstDB, DB, int64(txSize))
+	if closeErr := dstDB.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return nil, err
+	}
+	dstInfo, err := os.Stat(dst)
+	if err != nil {
+		return nil, err
+	}
+	if swap {
+		if err = swapDB(dst); err != nil {
+			return nil, err
+		}
+	}
+	return map[string]interface{}{
+		"before": fi.Size(),
+		"after":  dstInfo.Size(),
+	}, nil
+}
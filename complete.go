@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/chzyer/readline"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// completeBuckets completes the arguments with bucket names
+	completeBuckets = iota + 1
+	// completeKeys completes the arguments with bucket names and keys
+	completeKeys
+	// completeCmds completes the arguments with command names
+	completeCmds
+	// completeWords completes the arguments with the words in cmdWords
+	completeWords
+
+	// completionLimit caps the number of entries scanned in a bucket for completion
+	completionLimit = 1000
+)
+
+// cmdCompletions holds what the arguments of each command are completed with.
+// The arguments of the other commands are not completed.
+var cmdCompletions = map[string]int{
+	"bigkeys":     completeBuckets,
+	"buckets":     completeBuckets,
+	"bucketstats": completeBuckets,
+	"cas":         completeKeys,
+	"cd":          completeBuckets,
+	"decoder":     completeBuckets,
+	"decr":        completeKeys,
+	"del":         completeKeys,
+	"delglob":     completeBuckets,
+	"encoding":    completeWords,
+	"exists":      completeKeys,
+	"get":         completeKeys,
+	"getset":      completeKeys,
+	"help":        completeCmds,
+	"incr":        completeKeys,
+	"incrby":      completeKeys,
+	"keys":        completeBuckets,
+	"keyvalues":   completeBuckets,
+	"ls":          completeBuckets,
+	"meta":        completeKeys,
+	"mget":        completeKeys,
+	"mset":        completeKeys,
+	"nextseq":     completeBuckets,
+	"output":      completeWords,
+	"range":       completeBuckets,
+	"scan":        completeBuckets,
+	"search":      completeBuckets,
+	"set":         completeKeys,
+	"setnx":       completeKeys,
+	"setseq":      completeBuckets,
+	"strlen":      completeKeys,
+	"tree":        completeBuckets,
+	"type":        completeKeys,
+}
+
+var cmdWords = map[string][]string{
+	"encoding": encodings,
+	"output":   outputFormats,
+}
+
+type completer struct{}
+
+// splitTyped splits the typed line like splitArgs. It also returns the last argument as typed,
+// which is empty if a new argument is going to be typed.
+// An unterminated quote in the last argument is allowed.
+func splitTyped(typed string) ([]string, string, bool) {
+	args, starts, err := splitArgsWithStarts(typed)
+	if err == errUnbalancedQuotes {
+		// the last argument is being typed in quotes
+		for _, quote := range []string{`"`, `'`} {
+			args, starts, err = splitArgsWithStarts(typed + quote)
+			if err == nil {
+				return args, typed[starts[len(starts)-1]:], true
+			}
+		}
+	}
+	if err != nil {
+		return nil, "", false
+	}
+	if len(typed) == 0 || isSpace(typed[len(typed)-1]) {
+		return append(args, ""), "", true
+	}
+	return args, typed[starts[len(starts)-1]:], true
+}
+
+// quoteArg quotes the argument if it can't be typed as is
+func quoteArg(s string) string {
+	if s == "" {
+		return `""`
+	}
+	for _, r := range s {
+		if unicode.IsSpace(r) || r == '"' || r == '\'' || r == '\\' || !unicode.IsPrint(r) {
+			return `"` + escape(s) + `"`
+		}
+	}
+	return s
+}
+
+// Do implements readline.AutoCompleter. The line is split with the same rules
+// as the command input, so that quoted arguments are treated as a whole.
+func (c *completer) Do(line []rune, pos int) (newLine [][]rune, length int) {
+	args, typedArg, ok := splitTyped(string(line[:pos]))
+	if !ok {
+		return nil, 0
+	}
+	var candidates []string
+	if len(args) == 1 {
+		candidates = cmdNames()
+	} else {
+		candidates = completeArgs(strings.ToLower(args[0]), args[1:len(args)-1], args[len(args)-1])
+	}
+	sort.Strings(candidates)
+	seen := map[string]bool{}
+	for _, candidate := range candidates {
+		quoted := quoteArg(candidate)
+		if strings.HasPrefix(typedArg, `"`) {
+			quoted = `"` + escape(candidate) + `"`
+		}
+		if seen[quoted] || !strings.HasPrefix(quoted, typedArg) {
+			continue
+		}
+		seen[quoted] = true
+		newLine = append(newLine, []rune(quoted[len(typedArg):]+" "))
+	}
+	return newLine, len([]rune(typedArg))
+}
+
+func cmdNames() []string {
+	names := []string{}
+	for k := range CmdMap {
+		names = append(names, k)
+	}
+	return names
+}
+
+// completeArgs returns the candidates of the argument after given arguments of the command.
+func completeArgs(cmd string, args []string, cur string) []string {
+	kind := cmdCompletions[cmd]
+	switch kind {
+	case completeCmds:
+		if len(args) == 0 {
+			return cmdNames()
+		}
+		return nil
+	case completeWords:
+		if len(args) == 0 {
+			// copy it since the candidates will be sorted
+			return append([]string{}, cmdWords[cmd]...)
+		}
+		return nil
+	case completeBuckets, completeKeys:
+	default:
+		return nil
+	}
+
+	spec := cmdOptions[cmd]
+	if strings.HasPrefix(cur, "-") && len(spec) > 0 {
+		opts := []string{}
+		for opt := range spec {
+			opts = append(opts, opt)
+		}
+		return opts
+	}
+	path := []string{}
+	if pathCmds[cmd] || cmd == "cd" {
+		path = append(path, CurBucket...)
+	}
+	for i := 0; i < len(args); i++ {
+		if kind, ok := spec[args[i]]; ok {
+			if kind != optFlag {
+				i++
+			}
+			continue
+		}
+		name, err := decodeArg(args[i])
+		if err != nil {
+			return nil
+		}
+		path = append(path, name)
+	}
+	if len(args) > 0 {
+		if kind, ok := spec[args[len(args)-1]]; ok && kind != optFlag {
+			// the value of an option
+			return nil
+		}
+	}
+
+	candidates := []string{}
+	DB.View(func(tx *bolt.Tx) error {
+		var c *bolt.Cursor
+		var b *bolt.Bucket
+		if len(path) == 0 {
+			c = tx.Cursor()
+		} else {
+			b = findBucket(tx, path)
+			if b == nil {
+				// it is not a bucket path, for example, the value position of set
+				return nil
+			}
+			c = b.Cursor()
+		}
+
+		var k, v []byte
+		// Seek only works with the prefix in raw encoding
+		prefix := []byte(cur)
+		if Encoding == encodingRaw && len(prefix) > 0 {
+			k, v = c.Seek(prefix)
+		} else {
+			prefix = nil
+			k, v = c.First()
+		}
+		for i := 0; k != nil && i < completionLimit; i++ {
+			if !bytes.HasPrefix(k, prefix) {
+				break
+			}
+			// the values of buckets are nil
+			if v == nil || kind == completeKeys {
+				candidates = append(candidates, encodeOutput(string(k)))
+			}
+			k, v = c.Next()
+		}
+		return nil
+	})
+	return candidates
+}
+
+func buildCompleter() readline.AutoCompleter {
+	return &completer{}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+func complete(line string) []string {
+	candidates := []string{}
+	newLine, _ := buildCompleter().Do([]rune(line), len([]rune(line)))
+	for _, s := range newLine {
+		candidates = append(candidates, string(s))
+	}
+	return candidates
+}
+
+func (suite *CmdSuite) TestCompleter() {
+	newLine, length := buildCompleter().Do([]rune("key"), 3)
+	assert.Equal(suite.T(), 3, length)
+	assert.Equal(suite.T(), [][]rune{[]rune("s "), []rune("values ")}, newLine)
+	assert.Equal(suite.T(), []string{`t" `, `tset" `}, complete(`"ge`))
+
+	DB.Update(func(tx *bolt.Tx) error {
+		b, _ := tx.CreateBucket([]byte("bucket"))
+		b.Put([]byte("key"), []byte("value"))
+		b.Put([]byte("key with space"), []byte("value"))
+		b, _ = b.CreateBucket([]byte("kbucket"))
+		b.Put([]byte("key"), []byte("value"))
+		tx.CreateBucket([]byte("bucket2"))
+		tx.CreateBucket([]byte("other"))
+		return nil
+	})
+	assert.Equal(suite.T(), []string{"bucket ", "bucket2 ", "other "}, complete("get "))
+	assert.Equal(suite.T(), []string{"et ", "et2 "}, complete("get buck"))
+	assert.Equal(suite.T(), []string{"kbucket ", "key ", "\"key with space\" "},
+		complete("get bucket "))
+	assert.Equal(suite.T(), []string{`bucket" `, `ey" `, `ey with space" `}, complete(`get bucket "k`))
+	assert.Equal(suite.T(), []string{`ith space" `}, complete(`get bucket "key w`))
+	assert.Equal(suite.T(), []string{"key "}, complete("get bucket kbucket "))
+	// only buckets
+	assert.Equal(suite.T(), []string{"kbucket "}, complete("keys bucket "))
+	// no completion for values
+	assert.Equal(suite.T(), []string{}, complete("set bucket key "))
+	assert.Equal(suite.T(), []string{}, complete("get non-exist "))
+	// options
+	assert.Equal(suite.T(), []string{"depth ", "values "}, complete("tree -"))
+	assert.Equal(suite.T(), []string{}, complete("tree -depth "))
+	assert.Equal(suite.T(), []string{"kbucket "}, complete("tree -depth 1 bucket "))
+	// fixed words
+	assert.Equal(suite.T(), []string{"ex "}, complete("encoding h"))
+	assert.Equal(suite.T(), []string{"ree ", "ype "}, complete("help t"))
+
+	defer setEncoding(encodingRaw)
+	setEncoding(encodingHex)
+	assert.Equal(suite.T(), []string{"79 ", "792077697468207370616365 "}, complete("get 6275636b6574 6b65"))
+}
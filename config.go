@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// config is the content of the config file, like
+//
+//	{"decoders": {"/users": "json", "/counters": "u64be"}}
+type config struct {
+	// Decoders binds decoders to the buckets in the paths like /bucket/subbucket
+	Decoders map[string]string `json:"decoders"`
+}
+
+func defaultConfigPath() string {
+	return filepath.Join(getHomeDir(), ".config", "boltcli.json")
+}
+
+// loadConfig reads the config file and applies it. A missing file is ignored unless required is true.
+func loadConfig(path string, required bool) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && !required {
+			return nil
+		}
+		return err
+	}
+	var c config
+	if err = json.Unmarshal(data, &c); err != nil {
+		return fmt.Errorf("invalid config %s: %v", path, err)
+	}
+	for p, name := range c.Decoders {
+		if !isPath(p) {
+			return fmt.Errorf("invalid config %s: the bucket path '%s' doesn't start with '%s'", path, p, PathSeparator)
+		}
+		// the names are taken literally, so that the file means the same whatever the encoding is
+		bucket, hasKey, err := splitPath(p)
+		if err != nil {
+			return fmt.Errorf("invalid config %s: %v", path, err)
+		}
+		if len(bucket) == 0 || hasKey {
+			return fmt.Errorf("invalid config %s: decoders can only be bound to buckets", path)
+		}
+		if err = bindDecoder(bucket, name); err != nil {
+			return fmt.Errorf("invalid config %s: %v", path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// copyOptions are the options shared by copy, move and rename
+var copyOptions = optSpec{
+	"-overwrite": optFlag,
+}
+
+func init() {
+	cmdOptions["copy"] = copyOptions
+	cmdOptions["move"] = copyOptions
+	cmdOptions["rename"] = copyOptions
+}
+
+// bucketParent is what the transaction and the buckets have in common,
+// so that the top level buckets can be handled like the nested ones.
+type bucketParent interface {
+	bucketCreator
+	Bucket(name []byte) *bolt.Bucket
+	CreateBucket(name []byte) (*bolt.Bucket, error)
+	DeleteBucket(name []byte) error
+}
+
+// parentOf returns the parent of the last name in path, or nil if it doesn't exist.
+// If create is true, the missing buckets along the path are created.
+func parentOf(tx *bolt.Tx, path []string, create bool) (bucketParent, error) {
+	dir := path[:len(path)-1]
+	if len(dir) == 0 {
+		return tx, nil
+	}
+	if create {
+		b, err := createBucket(tx, dir)
+		if err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+	if b := findBucket(tx, dir); b != nil {
+		return b, nil
+	}
+	return nil, nil
+}
+
+// lookupEntry returns the bucket or the value with given name under the parent.
+// Both of them are nil if there is no such bucket or key.
+func lookupEntry(parent bucketParent, name []byte) (*bolt.Bucket, []byte) {
+	if b := parent.Bucket(name); b != nil {
+		return b, nil
+	}
+	if b, ok := parent.(*bolt.Bucket); ok {
+		return nil, b.Get(name)
+	}
+	return nil, nil
+}
+
+func deleteEntry(parent bucketParent, name []byte) error {
+	err := parent.DeleteBucket(name)
+	if err == bolt.ErrIncompatibleValue {
+		return parent.(*bolt.Bucket).Delete(name)
+	}
+	return err
+}
+
+// copyBucket copies the keys, the nested buckets and the sequence of src to dst
+func copyBucket(dst, src *bolt.Bucket) error {
+	if err := dst.SetSequence(src.Sequence()); err != nil {
+		return err
+	}
+	return src.ForEach(func(k, v []byte) error {
+		if sub := src.Bucket(k); sub != nil {
+			b, err := dst.CreateBucket(k)
+			if err != nil {
+				return err
+			}
+			return copyBucket(b, sub)
+		}
+		return dst.Put(k, v)
+	})
+}
+
+func isSubPath(path, parent []string) bool {
+	if len(path) < len(parent) {
+		return false
+	}
+	for i, name := range parent {
+		if path[i] != name {
+			return false
+		}
+	}
+	return true
+}
+
+// transfer copies the key or the bucket in src to dst in a single transaction,
+// and deletes src afterward if keepSrc is false.
+func transfer(src, dst []string, overwrite, keepSrc bool) error {
+	if len(src) == 0 || len(dst) == 0 {
+		return errors.New("the root can't be copied or moved")
+	}
+	if isSubPath(src, dst) && isSubPath(dst, src) {
+		return errors.New("the source and the destination are the same")
+	}
+	if isSubPath(src, dst) || isSubPath(dst, src) {
+		return fmt.Errorf("'%s' and '%s' overlap", formatPath(src), formatPath(dst))
+	}
+	return update(func(tx *bolt.Tx) error {
+		srcName := []byte(src[len(src)-1])
+		srcParent, _ := parentOf(tx, src, false)
+		var srcBucket *bolt.Bucket
+		var value []byte
+		if srcParent != nil {
+			srcBucket, value = lookupEntry(srcParent, srcName)
+		}
+		if srcBucket == nil && value == nil {
+			return fmt.Errorf("no such key or bucket: %s", formatPath(src))
+		}
+		if srcBucket == nil && len(dst) == 1 {
+			return errors.New("keys can't be put outside of buckets")
+		}
+
+		dstName := []byte(dst[len(dst)-1])
+		dstParent, err := parentOf(tx, dst, true)
+		if err != nil {
+			return err
+		}
+		if b, v := lookupEntry(dstParent, dstName); b != nil || v != nil {
+			if !overwrite {
+				return fmt.Errorf("%s already exists", formatPath(dst))
+			}
+			if err = deleteEntry(dstParent, dstName); err != nil {
+				return err
+			}
+		}
+
+		if srcBucket != nil {
+			b, err := dstParent.CreateBucket(dstName)
+			if err != nil {
+				return err
+			}
+			err = copyBucket(b, srcBucket)
+		} else {
+			err = dstParent.(*bolt.Bucket).Put(dstName, value)
+		}
+		if err != nil || keepSrc {
+			return err
+		}
+		return deleteEntry(srcParent, srcName)
+	})
+}
+
+// transferCmd implements copy, move and rename
+func transferCmd(cmd string, args []string) (res interface{}, err error) {
+	opts, args, err := parseOptions(cmd, args)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) != 2 {
+		return nil, fmt.Errorf("wrong number of arguments for '%s' command", cmd)
+	}
+	_, overwrite := opts["-overwrite"]
+	// the paths are resolved by resolveArgs, see absPathCmds
+	src, _, err := splitPath(args[0])
+	if err != nil {
+		return nil, err
+	}
+	var dst []string
+	if cmd == "rename" {
+		// the new name is always in the same bucket
+		if len(src) > 0 {
+			dst = append(append([]string{}, src[:len(src)-1]...), args[1])
+		}
+	} else {
+		dst, _, err = splitPath(args[1])
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = transfer(src, dst, overwrite, cmd == "copy")
+	if err != nil {
+		return nil, err
+	}
+	return true, nil
+}
+
+func copyCmd(args ...string) (res interface{}, err error) {
+	return transferCmd("copy", args)
+}
+
+func move(args ...string) (res interface{}, err error) {
+	return transferCmd("move", args)
+}
+
+func rename(args ...string) (res interface{}, err error) {
+	return transferCmd("rename", args)
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	counterDecimal = "decimal"
+	counterU64BE   = "u64be"
+	counterU64LE   = "u64le"
+)
+
+var (
+	counterFormats = []string{counterDecimal, counterU64BE, counterU64LE}
+
+	errCounterOverflow = errors.New("increment or decrement would overflow")
+)
+
+func init() {
+	counterOptions := optSpec{"-format": optValue}
+	cmdOptions["incr"] = counterOptions
+	cmdOptions["decr"] = counterOptions
+	cmdOptions["incrby"] = counterOptions
+}
+
+func counterFormat(opts map[string]string) (string, error) {
+	format, ok := opts["-format"]
+	if !ok {
+		return counterDecimal, nil
+	}
+	format = strings.ToLower(format)
+	for _, f := range counterFormats {
+		if f == format {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown format '%s', available formats: %s", format, strings.Join(counterFormats, ", "))
+}
+
+// addInt64 adds delta to n, and fails if the result overflows int64.
+func addInt64(n, delta int64) (int64, error) {
+	if (delta > 0 && n > math.MaxInt64-delta) || (delta < 0 && n < math.MinInt64-delta) {
+		return 0, errCounterOverflow
+	}
+	return n + delta, nil
+}
+
+// addUint64 adds delta to n, and fails if the result is negative or overflows uint64.
+func addUint64(n uint64, delta int64) (uint64, error) {
+	if delta >= 0 {
+		if n > math.MaxUint64-uint64(delta) {
+			return 0, errCounterOverflow
+		}
+		return n + uint64(delta), nil
+	}
+	// -delta overflows if delta is math.MinInt64
+	abs := uint64(-(delta + 1)) + 1
+	if n < abs {
+		return 0, errCounterOverflow
+	}
+	return n - abs, nil
+}
+
+// incrValue adds delta to the counter stored in given format. A missing value counts as 0.
+func incrValue(value []byte, format string, delta int64) ([]byte, interface{}, error) {
+	if format == counterDecimal {
+		var n int64
+		if value != nil {
+			var err error
+			n, err = strconv.ParseInt(string(value), 10, 64)
+			if err != nil {
+				return nil, nil, errors.New("value is not a decimal integer or out of range")
+			}
+		}
+		n, err := addInt64(n, delta)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []byte(strconv.FormatInt(n, 10)), n, nil
+	}
+
+	var order binary.ByteOrder = binary.BigEndian
+	if format == counterU64LE {
+		order = binary.LittleEndian
+	}
+	var n uint64
+	if value != nil {
+		if len(value) != 8 {
+			return nil, nil, fmt.Errorf("value is not a 64-bit integer, it has %d bytes", len(value))
+		}
+		n = order.Uint64(value)
+	}
+	n, err := addUint64(n, delta)
+	if err != nil {
+		return nil, nil, err
+	}
+	b := make([]byte, 8)
+	order.PutUint64(b, n)
+	return b, normalizeInt(n), nil
+}
+
+// incrCmd implements incr, decr and incrby. It reads and writes the counter in one transaction,
+// and returns the new value.
+func incrCmd(cmd string, args []string, delta int64) (res interface{}, err error) {
+	opts, args, err := parseOptions(cmd, args)
+	if err != nil {
+		return nil, err
+	}
+	argsLen := len(args)
+	if cmd == "incrby" {
+		if argsLen < 3 {
+			return nil, fmt.Errorf("wrong number of arguments for '%s' command", cmd)
+		}
+		delta, err = strconv.ParseInt(args[argsLen-1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("increment '%s' is not an integer or out of range", args[argsLen-1])
+		}
+		args = args[:argsLen-1]
+		argsLen--
+	}
+	if argsLen < 2 {
+		return nil, fmt.Errorf("wrong number of arguments for '%s' command", cmd)
+	}
+	format, err := counterFormat(opts)
+	if err != nil {
+		return nil, err
+	}
+	err = update(func(tx *bolt.Tx) error {
+		b, err := createBucket(tx, args[:argsLen-1])
+		if err != nil {
+			return err
+		}
+		key := []byte(args[argsLen-1])
+		if b.Bucket(key) != nil {
+			return bolt.ErrIncompatibleValue
+		}
+		value, n, err := incrValue(b.Get(key), format, delta)
+		if err != nil {
+			return err
+		}
+		res = n
+		return b.Put(key, value)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func incr(args ...string) (res interface{}, err error) {
+	return incrCmd("incr", args, 1)
+}
+
+func decr(args ...string) (res interface{}, err error) {
+	return incrCmd("decr", args, -1)
+}
+
+func incrby(args ...string) (res interface{}, err error) {
+	return incrCmd("incrby", args, 0)
+}
+
+// nextseq returns the next sequence of the bucket, which is created if it doesn't exist.
+func nextseq(args ...string) (res interface{}, err error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("wrong number of arguments for '%s' command", "nextseq")
+	}
+	err = update(func(tx *bolt.Tx) error {
+		b, err := createBucket(tx, args)
+		if err != nil {
+			return err
+		}
+		seq, err := b.NextSequence()
+		res = normalizeInt(seq)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// setseq sets the sequence of the bucket, which is created if it doesn't exist.
+func setseq(args ...string) (res interface{}, err error) {
+	argsLen := len(args)
+	if argsLen < 2 {
+		return nil, fmt.Errorf("wrong number of arguments for '%s' command", "setseq")
+	}
+	seq, err := strconv.ParseUint(args[argsLen-1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("sequence '%s' is not an unsigned integer or out of range", args[argsLen-1])
+	}
+	err = update(func(tx *bolt.Tx) error {
+		b, err := createBucket(tx, args[:argsLen-1])
+		if err != nil {
+			return err
+		}
+		return b.SetSequence(seq)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return true, nil
+}
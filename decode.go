@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// decoder converts a value to a structure for display. The structure is made of
+// nil, bool, int64, uint64, float64, string, dumpBytes, []interface{} and map[string]interface{}.
+type decoder func([]byte) (interface{}, error)
+
+// decoders is the registry of decoders which can be selected with the -as option,
+// or bound to a bucket with the decoder command.
+var decoders = map[string]decoder{
+	"json":     decodeJSON,
+	"msgpack":  decodeMsgpack,
+	"protobuf": decodeProtobuf,
+	"u64be":    fixedInt(8, binary.BigEndian, false),
+	"u64le":    fixedInt(8, binary.LittleEndian, false),
+	"i64be":    fixedInt(8, binary.BigEndian, true),
+	"i64le":    fixedInt(8, binary.LittleEndian, true),
+	"u32be":    fixedInt(4, binary.BigEndian, false),
+	"u32le":    fixedInt(4, binary.LittleEndian, false),
+	"i32be":    fixedInt(4, binary.BigEndian, true),
+	"i32le":    fixedInt(4, binary.LittleEndian, true),
+	"varint":   decodeVarint,
+	"uvarint":  decodeUvarint,
+	"unix":     unixTime(time.Second),
+	"unixms":   unixTime(time.Millisecond),
+	"unixns":   unixTime(time.Nanosecond),
+	"gotime":   decodeGoTime,
+}
+
+func init() {
+	cmdOptions["get"] = optSpec{"-as": optValue}
+	cmdOptions["keyvalues"] = optSpec{"-as": optValue}
+}
+
+// decoderNone unbinds the decoder of a bucket
+const decoderNone = "none"
+
+// Decoded is a value converted by a decoder. It is shown as indented json in the text output,
+// and as tables in Lua.
+type Decoded struct {
+	Value interface{}
+}
+
+func (d Decoded) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Value)
+}
+
+func (d Decoded) String() string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(d.Value); err != nil {
+		return fmt.Sprintf("%v", d.Value)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func decoderNames() []string {
+	names := []string{}
+	for name := range decoders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func findDecoder(name string) (decoder, error) {
+	dec, ok := decoders[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown decoder '%s', available decoders: %s", name, strings.Join(decoderNames(), ", "))
+	}
+	return dec, nil
+}
+
+// valueDecoder returns the decoder with given name (from the -as option), or the decoder bound
+// to the bucket if the name is empty. explicit reports whether the decoder is given by name.
+func valueDecoder(name string, bucket []string) (dec decoder, explicit bool, err error) {
+	if name != "" {
+		dec, err = findDecoder(name)
+		return dec, true, err
+	}
+	if name := boundDecoder(bucket); name != "" {
+		return decoders[name], false, nil
+	}
+	return nil, false, nil
+}
+
+// decodeValue converts the value with the decoder if there is one.
+// If the decoder is bound to the bucket instead of given explicitly,
+// the value is kept as is when it can't be decoded.
+func decodeValue(dec decoder, explicit bool, key, value []byte) (interface{}, error) {
+	if dec == nil {
+		return string(value), nil
+	}
+	decoded, err := dec(value)
+	if err != nil {
+		if !explicit {
+			return string(value), nil
+		}
+		return nil, fmt.Errorf("can't decode the value of key '%s': %v", encodeOutput(string(key)), err)
+	}
+	return Decoded{decoded}, nil
+}
+
+// normalizeInt converts an integer to int64 if it fits, so that most integers have the same type.
+func normalizeInt(n uint64) interface{} {
+	if n <= math.MaxInt64 {
+		return int64(n)
+	}
+	return n
+}
+
+func decodeJSON(b []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("invalid json: extra data after the value")
+	}
+	return convertJSONNumbers(v), nil
+}
+
+func convertJSONNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		if n, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case []interface{}:
+		for i := range v {
+			v[i] = convertJSONNumbers(v[i])
+		}
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = convertJSONNumbers(v[k])
+		}
+	}
+	return v
+}
+
+func checkSize(b []byte, size int) error {
+	if len(b) != size {
+		return fmt.Errorf("expected %d bytes, got %d bytes", size, len(b))
+	}
+	return nil
+}
+
+func fixedInt(size int, order binary.ByteOrder, signed bool) decoder {
+	return func(b []byte) (interface{}, error) {
+		if err := checkSize(b, size); err != nil {
+			return nil, err
+		}
+		if size == 4 {
+			n := order.Uint32(b)
+			if signed {
+				return int64(int32(n)), nil
+			}
+			return int64(n), nil
+		}
+		n := order.Uint64(b)
+		if signed {
+			return int64(n), nil
+		}
+		return normalizeInt(n), nil
+	}
+}
+
+func decodeVarint(b []byte) (interface{}, error) {
+	n, size := binary.Varint(b)
+	if size <= 0 || size != len(b) {
+		return nil, errors.New("invalid varint")
+	}
+	return n, nil
+}
+
+func decodeUvarint(b []byte) (interface{}, error) {
+	n, size := binary.Uvarint(b)
+	if size <= 0 || size != len(b) {
+		return nil, errors.New("invalid uvarint")
+	}
+	return normalizeInt(n), nil
+}
+
+func isDecimal(b []byte) bool {
+	for i, c := range b {
+		if !(c >= '0' && c <= '9') && !(i == 0 && c == '-' && len(b) > 1) {
+			return false
+		}
+	}
+	return len(b) > 0
+}
+
+// unixTime decodes a Unix timestamp in given unit, which is stored as a decimal string
+// or a big-endian 64-bit integer, to a RFC 3339 time in UTC.
+func unixTime(unit time.Duration) decoder {
+	return func(b []byte) (interface{}, error) {
+		var n int64
+		if isDecimal(b) {
+			var err error
+			if n, err = strconv.ParseInt(string(b), 10, 64); err != nil {
+				return nil, err
+			}
+		} else {
+			if err := checkSize(b, 8); err != nil {
+				return nil, err
+			}
+			n = int64(binary.BigEndian.Uint64(b))
+		}
+		perSec := int64(time.Second / unit)
+		t := time.Unix(n/perSec, n%perSec*int64(unit))
+		return t.UTC().Format(time.RFC3339Nano), nil
+	}
+}
+
+// decodeGoTime decodes a time.Time encoded with its MarshalBinary method
+func decodeGoTime(b []byte) (interface{}, error) {
+	var t time.Time
+	if err := t.UnmarshalBinary(b); err != nil {
+		return nil, err
+	}
+	return t.Format(time.RFC3339Nano), nil
+}
+
+// decoderBinding binds a decoder to the values in a bucket
+type decoderBinding struct {
+	path    []string
+	decoder string
+}
+
+// decoderBindings holds the decoders bound to buckets, either by the decoder command or in the config.
+var decoderBindings = []decoderBinding{}
+
+func samePath(a, b []string) bool {
+	return len(a) == len(b) && isSubPath(a, b)
+}
+
+func boundDecoder(path []string) string {
+	for _, binding := range decoderBindings {
+		if samePath(binding.path, path) {
+			return binding.decoder
+		}
+	}
+	return ""
+}
+
+// bindDecoder binds the decoder to the bucket, or unbinds it if the decoder is "none".
+func bindDecoder(path []string, name string) error {
+	name = strings.ToLower(name)
+	if name != decoderNone {
+		if _, err := findDecoder(name); err != nil {
+			return err
+		}
+	}
+	for i, binding := range decoderBindings {
+		if samePath(binding.path, path) {
+			decoderBindings = append(decoderBindings[:i], decoderBindings[i+1:]...)
+			break
+		}
+	}
+	if name != decoderNone {
+		decoderBindings = append(decoderBindings, decoderBinding{path, name})
+	}
+	return nil
+}
+
+func decoderCmd(args ...string) (res interface{}, err error) {
+	if len(args) > 2 {
+		return nil, fmt.Errorf("wrong number of arguments for '%s' command", "decoder")
+	}
+	if len(args) == 0 {
+		bindings := map[string]interface{}{}
+		for _, binding := range decoderBindings {
+			bindings[formatPath(binding.path)] = binding.decoder
+		}
+		return bindings, nil
+	}
+	path, err := resolvePath(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(path) == 0 {
+		return nil, errors.New("decoders can only be bound to buckets")
+	}
+	if len(args) == 2 {
+		if err = bindDecoder(path, args[1]); err != nil {
+			return nil, err
+		}
+	}
+	if name := boundDecoder(path); name != "" {
+		return name, nil
+	}
+	return decoderNone, nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func decodeHex(t *testing.T, name, s string) (interface{}, error) {
+	b, err := hex.DecodeString(s)
+	assert.Nil(t, err)
+	dec, err := findDecoder(name)
+	assert.Nil(t, err)
+	return dec(b)
+}
+
+func mustMarshal(t *testing.T, v interface{}) string {
+	b, err := json.Marshal(v)
+	assert.Nil(t, err)
+	return string(b)
+}
+
+func TestDecodeJSON(t *testing.T) {
+	v, err := decodeJSON([]byte(`{"a": [1, 2.5, "x", null, true], "big": 18446744073709551615}`))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"a":   []interface{}{int64(1), 2.5, "x", nil, true},
+		"big": uint64(18446744073709551615),
+	}, v)
+	assert.Equal(t, "{\n  \"a\": 1\n}", Decoded{map[string]interface{}{"a": int64(1)}}.String())
+
+	_, err = decodeJSON([]byte(`{"a": 1} {}`))
+	assert.NotNil(t, err)
+	_, err = decodeJSON([]byte(`{"a"`))
+	assert.NotNil(t, err)
+}
+
+func TestDecodeInts(t *testing.T) {
+	v, err := decodeHex(t, "u64be", "000000000000002a")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(42), v)
+	v, _ = decodeHex(t, "u64le", "2a00000000000000")
+	assert.Equal(t, int64(42), v)
+	v, _ = decodeHex(t, "u64be", "ffffffffffffffff")
+	assert.Equal(t, uint64(18446744073709551615), v)
+	v, _ = decodeHex(t, "i64be", "ffffffffffffffff")
+	assert.Equal(t, int64(-1), v)
+	v, _ = decodeHex(t, "i32le", "feffffff")
+	assert.Equal(t, int64(-2), v)
+	v, _ = decodeHex(t, "u32be", "ffffffff")
+	assert.Equal(t, int64(4294967295), v)
+	v, _ = decodeHex(t, "varint", "03")
+	assert.Equal(t, int64(-2), v)
+	v, _ = decodeHex(t, "uvarint", "ac02")
+	assert.Equal(t, int64(300), v)
+
+	_, err = decodeHex(t, "u64be", "2a")
+	assert.Equal(t, "expected 8 bytes, got 1 bytes", err.Error())
+	_, err = decodeHex(t, "uvarint", "ac02ff")
+	assert.NotNil(t, err)
+
+	_, err = findDecoder("yaml")
+	assert.Contains(t, err.Error(), "unknown decoder 'yaml', available decoders: gotime, i32be")
+}
+
+func TestDecodeTime(t *testing.T) {
+	v, err := decoders["unix"]([]byte("1500000000"))
+	assert.Nil(t, err)
+	assert.Equal(t, "2017-07-14T02:40:00Z", v)
+	v, _ = decodeHex(t, "unix", "0000000059682f00")
+	assert.Equal(t, "2017-07-14T02:40:00Z", v)
+	v, _ = decoders["unixms"]([]byte("1500000000123"))
+	assert.Equal(t, "2017-07-14T02:40:00.123Z", v)
+	v, _ = decoders["unixns"]([]byte("-1"))
+	assert.Equal(t, "1969-12-31T23:59:59.999999999Z", v)
+
+	b, _ := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC).MarshalBinary()
+	v, err = decodeGoTime(b)
+	assert.Nil(t, err)
+	assert.Equal(t, "2020-01-02T03:04:05Z", v)
+}
+
+func TestDecodeMsgpack(t *testing.T) {
+	// {"a": [1, -1, 300, "s", nil, true, 1.5], 1: bin "\xff", "t": timestamp 1500000000}
+	v, err := decodeHex(t, "msgpack", "83"+
+		"a161"+"9701ffcd012ca173c0c3cb3ff8000000000000"+
+		"01"+"c401ff"+
+		"a174"+"d6ff59682f00")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"a": []interface{}{int64(1), int64(-1), int64(300), "s", nil, true, 1.5},
+		"1": dumpBytes{0xff},
+		"t": "2017-07-14T02:40:00Z",
+	}, v)
+	assert.Equal(t, `{"1":{"base64":"/w=="},"a":[1,-1,300,"s",null,true,1.5],"t":"2017-07-14T02:40:00Z"}`,
+		mustMarshal(t, Decoded{v}))
+
+	v, _ = decodeHex(t, "msgpack", "d3ffffffffffffff85")
+	assert.Equal(t, int64(-123), v)
+	v, _ = decodeHex(t, "msgpack", "d40102")
+	assert.Equal(t, map[string]interface{}{"type": int64(1), "data": dumpBytes{2}}, v)
+
+	_, err = decodeHex(t, "msgpack", "92a161")
+	assert.Equal(t, errTruncated, err)
+	_, err = decodeHex(t, "msgpack", "0101")
+	assert.NotNil(t, err)
+	_, err = decodeHex(t, "msgpack", "c1")
+	assert.NotNil(t, err)
+	// a huge array length can't be satisfied by the data
+	_, err = decodeHex(t, "msgpack", "ddffffffff")
+	assert.Equal(t, errTruncated, err)
+}
+
+func TestDecodeProtobuf(t *testing.T) {
+	// 1: 150, 2: "hi", 3: {1: 1}, 3: {1: 2}, 4: fixed32 1, 5: fixed64 2, 6: bytes "\xff"
+	v, err := decodeHex(t, "protobuf", "089601"+"12026869"+"1a020801"+"1a020802"+
+		"2501000000"+"290200000000000000"+"3201ff")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"1": int64(150),
+		"2": "hi",
+		"3": []interface{}{
+			map[string]interface{}{"1": int64(1)},
+			map[string]interface{}{"1": int64(2)},
+		},
+		"4": int64(1),
+		"5": int64(2),
+		"6": dumpBytes{0xff},
+	}, v)
+
+	_, err = decodeHex(t, "protobuf", "0a05")
+	assert.Equal(t, errTruncated, err)
+	_, err = decodeHex(t, "protobuf", "0b")
+	assert.Equal(t, "unsupported wire type 3 in field 1", err.Error())
+	_, err = decodeHex(t, "protobuf", "00")
+	assert.NotNil(t, err)
+}
+
+func TestLoadConfig(t *testing.T) {
+	defer func() { decoderBindings = []decoderBinding{} }()
+	f, _ := ioutil.TempFile("", "boltcli")
+	defer os.Remove(f.Name())
+	f.WriteString(`{"decoders": {"/a/b": "json", "/c": "U64BE"}}`)
+	f.Close()
+
+	assert.Nil(t, loadConfig(f.Name(), true))
+	assert.Equal(t, "json", boundDecoder([]string{"a", "b"}))
+	assert.Equal(t, "u64be", boundDecoder([]string{"c"}))
+	assert.Equal(t, "", boundDecoder([]string{"a"}))
+
+	assert.Nil(t, loadConfig(f.Name()+".nonexistent", false))
+	assert.NotNil(t, loadConfig(f.Name()+".nonexistent", true))
+
+	ioutil.WriteFile(f.Name(), []byte(`{"decoders": {"a": "json"}}`), 0600)
+	assert.Equal(t, "invalid config "+f.Name()+": the bucket path 'a' doesn't start with '/'",
+		loadConfig(f.Name(), true).Error())
+	ioutil.WriteFile(f.Name(), []byte(`{"decoders": {"/a": "yaml"}}`), 0600)
+	assert.NotNil(t, loadConfig(f.Name(), true))
+	ioutil.WriteFile(f.Name(), []byte(`{"decoders": {"/a:key": "json"}}`), 0600)
+	assert.Equal(t, "invalid config "+f.Name()+": decoders can only be bound to buckets",
+		loadConfig(f.Name(), true).Error())
+
+	// the paths don't depend on the encoding
+	defer setEncoding(encodingRaw)
+	setEncoding(encodingHex)
+	ioutil.WriteFile(f.Name(), []byte(`{"decoders": {"/users": "json"}}`), 0600)
+	assert.Nil(t, loadConfig(f.Name(), true))
+	assert.Equal(t, "json", boundDecoder([]string{"users"}))
+}
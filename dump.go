@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"unicode/utf8"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// dumpVersion is the version of the dump format. The format looks like:
+//
+//	{
+//	  "version": 1,
+//	  "buckets": [
+//	    {
+//	      "name": "bucket",
+//	      "sequence": 2,
+//	      "keys": [
+//	        {"key": "key", "value": "value"},
+//	        {"key": "binary", "value": {"base64": "AAE="}}
+//	      ],
+//	      "buckets": [{"name": "nested bucket", "keys": []}]
+//	    }
+//	  ]
+//	}
+//
+// Bucket names, keys and values are written as json strings if they are valid UTF-8,
+// otherwise as an object which holds the base64 encoded bytes.
+// The sequence is omitted when it is zero.
+const dumpVersion = 1
+
+type dumpBytes []byte
+
+type dumpKeyValue struct {
+	Key   dumpBytes `json:"key"`
+	Value dumpBytes `json:"value"`
+}
+
+type dumpBucket struct {
+	Name     dumpBytes      `json:"name"`
+	Sequence uint64         `json:"sequence,omitempty"`
+	Keys     []dumpKeyValue `json:"keys"`
+	Buckets  []*dumpBucket  `json:"buckets,omitempty"`
+}
+
+type dumpFile struct {
+	Version int           `json:"version"`
+	Buckets []*dumpBucket `json:"buckets"`
+}
+
+type base64Bytes struct {
+	Base64 string `json:"base64"`
+}
+
+func (b dumpBytes) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(base64Bytes{base64.StdEncoding.EncodeToString(b)})
+}
+
+func (b *dumpBytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*b = dumpBytes(s)
+		return nil
+	}
+	var encoded base64Bytes
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded.Base64)
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// dumpCounter counts the buckets and keys which are dumped or restored
+type dumpCounter struct {
+	buckets int64
+	keys    int64
+}
+
+func (c *dumpCounter) result() map[string]interface{} {
+	return map[string]interface{}{
+		"buckets": c.buckets,
+		"keys":    c.keys,
+	}
+}
+
+func dumpBucketTree(name []byte, b *bolt.Bucket, counter *dumpCounter) (*dumpBucket, error) {
+	counter.buckets++
+	// copy the name since it is only valid in the transaction
+	d := &dumpBucket{
+		Name:     append(dumpBytes{}, name...),
+		Sequence: b.Sequence(),
+		Keys:     []dumpKeyValue{},
+	}
+	err := b.ForEach(func(k, v []byte) error {
+		sub := b.Bucket(k)
+		if sub == nil {
+			counter.keys++
+			d.Keys = append(d.Keys, dumpKeyValue{
+				Key:   append(dumpBytes{}, k...),
+				Value: append(dumpBytes{}, v...),
+			})
+			return nil
+		}
+		dumped, err := dumpBucketTree(k, sub, counter)
+		if err != nil {
+			return err
+		}
+		d.Buckets = append(d.Buckets, dumped)
+		return nil
+	})
+	return d, err
+}
+
+func dump(args ...string) (res interface{}, err error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("wrong number of arguments for '%s' command", "dump")
+	}
+	if sameFile(args[0], DbPath) {
+		return nil, errors.New("can't dump the database to itself")
+	}
+	counter := &dumpCounter{}
+	data := dumpFile{Version: dumpVersion, Buckets: []*dumpBucket{}}
+	err = view(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			d, err := dumpBucketTree(name, b, counter)
+			if err != nil {
+				return err
+			}
+			data.Buckets = append(data.Buckets, d)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Create(args[0])
+	if err != nil {
+		return nil, err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	err = enc.Encode(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return counter.result(), nil
+}
+
+type bucketCreator interface {
+	CreateBucketIfNotExists(name []byte) (*bolt.Bucket, error)
+}
+
+func restoreBucketTree(parent bucketCreator, d *dumpBucket, counter *dumpCounter) error {
+	b, err := parent.CreateBucketIfNotExists(d.Name)
+	if err != nil {
+		return fmt.Errorf("failed to create bucket '%s': %v", d.Name, err)
+	}
+	counter.buckets++
+	if d.Sequence != 0 {
+		if err = b.SetSequence(d.Sequence); err != nil {
+			return err
+		}
+	}
+	for _, kv := range d.Keys {
+		if err = b.Put(kv.Key, kv.Value); err != nil {
+			return fmt.Errorf("failed to set key '%s' in bucket '%s': %v", kv.Key, d.Name, err)
+		}
+		counter.keys++
+	}
+	for _, sub := range d.Buckets {
+		if err = restoreBucketTree(b, sub, counter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func restore(args ...string) (res interface{}, err error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("wrong number of arguments for '%s' command", "restore")
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var data dumpFile
+	if err = json.NewDecoder(f).Decode(&data); err != nil {
+		return nil, fmt.Errorf("invalid dump file: %v", err)
+	}
+	if data.Version != dumpVersion {
+		return nil, fmt.Errorf("unsupported dump version %d", data.Version)
+	}
+
+	counter := &dumpCounter{}
+	err = update(func(tx *bolt.Tx) error {
+		for _, d := range data.Buckets {
+			if err := restoreBucketTree(tx, d, counter); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counter.result(), nil
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	encodingRaw     = "raw"
+	encodingHex     = "hex"
+	encodingBase64  = "base64"
+	encodingEscaped = "escaped"
+)
+
+var (
+	// Encoding is the session wide encoding of keys and values.
+	// It applies to the arguments typed in the command line and to the output of commands.
+	Encoding = encodingRaw
+
+	encodings = []string{encodingRaw, encodingHex, encodingBase64, encodingEscaped}
+
+	// plainCmds are the commands whose arguments and output are not about the data,
+	// or which decode their arguments by themselves, so they are never decoded or encoded.
+	plainCmds = map[string]bool{
+		"backup":   true,
+		"cd":       true,
+		"compact":  true,
+		"decoder":  true,
+		"discard":  true,
+		"dump":     true,
+		"encoding": true,
+		"help":     true,
+		"info":     true,
+		"multi":    true,
+		"output":   true,
+		"page":     true,
+		"pages":    true,
+		"pwd":      true,
+		"restore":  true,
+	}
+
+	// numArgCmds are the commands whose last argument is a number instead of data,
+	// so it is never decoded.
+	numArgCmds = map[string]bool{
+		"incrby": true,
+		"setseq": true,
+	}
+)
+
+func setEncoding(enc string) error {
+	enc = strings.ToLower(enc)
+	for _, e := range encodings {
+		if e == enc {
+			Encoding = e
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown encoding '%s', available encodings: %s", enc, strings.Join(encodings, ", "))
+}
+
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		c, n := unescapeAt(s, i)
+		b.WriteByte(c)
+		i += n - 1
+	}
+	return b.String()
+}
+
+// escape is the reverse of unescape. It keeps printable UTF-8 characters
+// and replaces everything else with backslash escapes.
+func escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == '\\' || r == '"':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == utf8.RuneError && size == 1, !unicode.IsPrint(r):
+			for j := i; j < i+size; j++ {
+				fmt.Fprintf(&b, `\x%02x`, s[j])
+			}
+		default:
+			b.WriteRune(r)
+		}
+		i += size
+	}
+	return b.String()
+}
+
+// decodeArg converts an argument typed in the command line to the bytes it stands for.
+// The prefixes "0x" (hex), "b64:" (base64) and "raw:" (taken literally) take precedence
+// over the session wide Encoding.
+func decodeArg(arg string) (string, error) {
+	enc := Encoding
+	switch {
+	case strings.HasPrefix(arg, "0x"):
+		enc, arg = encodingHex, arg[2:]
+	case strings.HasPrefix(arg, "b64:"):
+		enc, arg = encodingBase64, arg[4:]
+	case strings.HasPrefix(arg, "raw:"):
+		enc, arg = encodingRaw, arg[4:]
+	}
+	switch enc {
+	case encodingHex:
+		b, err := hex.DecodeString(arg)
+		if err != nil {
+			return "", fmt.Errorf("invalid hex argument '%s': %v", arg, err)
+		}
+		return string(b), nil
+	case encodingBase64:
+		b, err := base64.StdEncoding.DecodeString(arg)
+		if err != nil {
+			return "", fmt.Errorf("invalid base64 argument '%s': %v", arg, err)
+		}
+		return string(b), nil
+	case encodingEscaped:
+		return unescape(arg), nil
+	default:
+		return arg, nil
+	}
+}
+
+// lastArgIndex returns the index of the last argument which is not an option, or -1 if there is none.
+func lastArgIndex(cmd string, args []string) int {
+	spec := cmdOptions[cmd]
+	last := -1
+	for i := 0; i < len(args); i++ {
+		if spec != nil && args[i] == "--" {
+			spec = nil
+			continue
+		}
+		if kind, ok := spec[args[i]]; ok {
+			if kind != optFlag {
+				i++
+			}
+			continue
+		}
+		last = i
+	}
+	return last
+}
+
+// decodeArgs decodes the arguments of given command. Options are kept as is,
+// and so are their values, unless the values are data.
+func decodeArgs(cmd string, args []string) ([]string, error) {
+	spec := cmdOptions[cmd]
+	numArg := -1
+	if numArgCmds[cmd] {
+		numArg = lastArgIndex(cmd, args)
+	}
+	decoded := make([]string, len(args))
+	copy(decoded, args)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if spec != nil && arg == "--" {
+			spec = nil
+			continue
+		}
+		if kind, ok := spec[arg]; ok {
+			if kind != optFlag {
+				i++
+			}
+			if kind != optData || i >= len(args) {
+				continue
+			}
+		}
+		if i == numArg {
+			continue
+		}
+		s, err := decodeArg(args[i])
+		if err != nil {
+			return nil, err
+		}
+		decoded[i] = s
+	}
+	return decoded, nil
+}
+
+// encodeOutput converts the data read from the database to the session wide Encoding.
+func encodeOutput(s string) string {
+	switch Encoding {
+	case encodingHex:
+		return hex.EncodeToString([]byte(s))
+	case encodingBase64:
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	case encodingEscaped:
+		return escape(s)
+	default:
+		return s
+	}
+}
+
+// encodeResult applies encodeOutput to the data inside a command result.
+// In a map, string values and their keys are data (like the result of keyvalues),
+// and so are the keys of decoded values, while the keys of the other values are treated
+// as labels (like the result of stats). Decoded values are shown as they are.
+func encodeResult(res interface{}) interface{} {
+	switch res := res.(type) {
+	case []byte:
+		return encodeOutput(string(res))
+	case string:
+		return encodeOutput(res)
+	case []string:
+		encoded := make([]string, len(res))
+		for i, s := range res {
+			encoded[i] = encodeOutput(s)
+		}
+		return encoded
+	case []interface{}:
+		encoded := make([]interface{}, len(res))
+		for i, v := range res {
+			encoded[i] = encodeResult(v)
+		}
+		return encoded
+	case map[string]interface{}:
+		encoded := make(map[string]interface{}, len(res))
+		for k, v := range res {
+			switch v := v.(type) {
+			case string:
+				encoded[encodeOutput(k)] = encodeOutput(v)
+			case Decoded:
+				encoded[encodeOutput(k)] = v
+			default:
+				encoded[k] = encodeResult(v)
+			}
+		}
+		return encoded
+	default:
+		return res
+	}
+}
+
+// encodeCmdResult encodes the result of given command with the session wide Encoding,
+// unless the command is plain or encodes its result by itself.
+// The results of exec are encoded one by one with the rules of the queued commands.
+func encodeCmdResult(cmd string, res interface{}) interface{} {
+	if r, ok := res.(execResults); ok {
+		encoded := make([]interface{}, len(r.results))
+		for i, v := range r.results {
+			encoded[i] = encodeCmdResult(r.cmds[i], v)
+		}
+		return encoded
+	}
+	if plainCmds[cmd] || selfEncodedCmds[cmd] {
+		return res
+	}
+	return encodeResult(res)
+}
+
+func encoding(args ...string) (res interface{}, err error) {
+	if len(args) > 1 {
+		return nil, fmt.Errorf("wrong number of arguments for '%s' command", "encoding")
+	}
+	if len(args) == 1 {
+		err = setEncoding(args[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return Encoding, nil
+}
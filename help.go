@@ -5,6 +5,98 @@ import (
 )
 
 var CmdHelp = map[string][2]string{
+	"backup": [2]string{
+		"file [-gzip] [-checksum]",
+		strings.Join([]string{
+			"Writes a consistent snapshot of the database to the file in a read-only transaction, and returns its size.",
+			"Other readers and writers are not blocked while the snapshot is written.",
+			"If -gzip is given, the snapshot is compressed with gzip.",
+			"If -checksum is given, the sha256 of the file is returned and written to file.sha256 as sha256sum does.",
+			"Fails if the file already exists, and can't be run in a transaction.",
+		}, "\n"),
+	},
+	"bigkeys": [2]string{
+		"[bucket ...] [-limit N]",
+		strings.Join([]string{
+			"Finds the keys with the largest values in the specified bucket and all its nested buckets,",
+			"or in the whole database if no bucket is given, like redis-cli --bigkeys.",
+			"Returns the N (10 by default) largest ones as a list of [path, size], the largest first.",
+		}, "\n"),
+	},
+	"bucketstats": [2]string{
+		"[bucket ...] [-recursive] [-sort name|size|keys] [-limit N]",
+		strings.Join([]string{
+			"Returns the statistics of the specified bucket, or of each top level bucket if no bucket is given,",
+			"as a list of [path, statistics]. The statistics of a bucket include its nested buckets.",
+			"Inuse is the bytes used by the bucket, and Alloc is the bytes of the pages allocated for it.",
+			"If -recursive is given, the nested buckets at any level are listed as well.",
+			"The list is sorted by the path by default, or by Inuse or KeyN in descending order with -sort.",
+			"If -limit is given, only returns the first N buckets.",
+		}, "\n"),
+	},
+	"cas": [2]string{
+		"[bucket ...] bucket key expected value",
+		strings.Join([]string{
+			"Sets the value of the given key only if its current value equals the expected one,",
+			"and returns whether the value is set. The check and the write are done in one transaction.",
+			"Returns false if the key does not exist.",
+		}, "\n"),
+	},
+	"cd": [2]string{
+		"[bucket ...]",
+		strings.Join([]string{
+			"Changes the current bucket to the specified bucket under it, and returns the new path.",
+			"'..' goes to the parent bucket, while '/' or no argument goes to the root.",
+			"An argument like /bucket/subbucket is an absolute path.",
+			"The bucket path of other commands is relative to the current bucket,",
+			"unless it is written as an absolute path like /bucket/subbucket:key.",
+			"A bucket whose name starts with '/' is written like raw:/name.",
+		}, "\n"),
+	},
+	"check": [2]string{
+		"",
+		strings.Join([]string{
+			"Checks the consistency of the database, and returns the number of buckets and keys checked.",
+			"Besides the pages checked by bolt, keys should be non-empty and sorted,",
+			"and entries without value should be buckets.",
+			"Fails with the number of problems if any problem is found. In text output,",
+			"the problems are printed as soon as they are found, otherwise they are included in the error.",
+		}, "\n"),
+	},
+	"compact": [2]string{
+		"file [-txsize bytes] [-swap]",
+		strings.Join([]string{
+			"Copies all buckets into a new database file to reclaim the free space, and returns the file sizes before and after.",
+			"The copy is committed every -txsize bytes, 65536 by default, and 0 means a single transaction.",
+			"If -swap is given, the compacted file replaces the database, which is reopened.",
+		}, "\n"),
+	},
+	"copy": [2]string{
+		"source destination [-overwrite]",
+		strings.Join([]string{
+			"Copies the key or the bucket with all its nested buckets to the destination in one transaction, and returns true.",
+			"The source and the destination are either a name in the current bucket or a path like /bucket/subbucket:key.",
+			"The missing buckets in the destination path are created.",
+			"Fails if the destination exists, unless -overwrite is given.",
+		}, "\n"),
+	},
+	"decoder": [2]string{
+		"[bucket [decoder]]",
+		strings.Join([]string{
+			"Binds the decoder to the values in the bucket, so that get, keyvalues and range decode them for display.",
+			"The bucket is either a name in the current bucket or a path like /bucket/subbucket.",
+			"Decoder 'none' unbinds the decoder. Without a decoder, returns the decoder bound to the bucket.",
+			"Without arguments, returns all bound decoders. Decoders can also be bound in the config file.",
+			"A value which can't be decoded by the bound decoder is shown as it is.",
+			"Available decoders: " + strings.Join(decoderNames(), ", "),
+		}, "\n"),
+	},
+	"decr": [2]string{
+		"[bucket ...] bucket key [-format decimal|u64be|u64le]",
+		strings.Join([]string{
+			"Like incr, but decrements the counter by one.",
+		}, "\n"),
+	},
 	"del": [2]string{
 		"[bucket ...] bucket/key",
 		strings.Join([]string{
@@ -20,6 +112,35 @@ var CmdHelp = map[string][2]string{
 			"If bucket does not exist, returns 0",
 		}, "\n"),
 	},
+	"discard": [2]string{
+		"",
+		strings.Join([]string{
+			"Discards the commands queued after multi.",
+		}, "\n"),
+	},
+	"dump": [2]string{
+		"file",
+		strings.Join([]string{
+			"Writes all buckets, keys and values to the given file in json, and returns the number of buckets and keys.",
+			"Names and values which are not valid UTF-8 are written as {\"base64\": \"...\"}.",
+			"See the restore command for loading the file.",
+		}, "\n"),
+	},
+	"encoding": [2]string{
+		"[raw|hex|base64|escaped]",
+		strings.Join([]string{
+			"Sets the encoding of keys and values in the command line, and returns the current encoding.",
+			"The arguments are decoded from the encoding and the output is encoded to it.",
+			"An argument with the prefix 0x, b64: or raw: is decoded as hex, base64 or taken literally, whatever the encoding is.",
+		}, "\n"),
+	},
+	"exec": [2]string{
+		"",
+		strings.Join([]string{
+			"Runs the commands queued after multi in a single transaction, and returns the list of their results.",
+			"If a command fails, the transaction is rolled back and none of the changes are kept.",
+		}, "\n"),
+	},
 	"exists": [2]string{
 		"[bucket ...] bucket/key",
 		strings.Join([]string{
@@ -28,10 +149,19 @@ var CmdHelp = map[string][2]string{
 		}, "\n"),
 	},
 	"get": [2]string{
-		"[bucket ...] bucket key",
+		"[bucket ...] bucket key [-as decoder]",
 		strings.Join([]string{
 			"Returns the value of the given key in the specified bucket.",
 			"Returns an empty string if the bucket or key does not exist.",
+			"If -as is given, or a decoder is bound to the bucket, the value is decoded for display,",
+			"like pretty-printed json. See the decoder command for the available decoders.",
+		}, "\n"),
+	},
+	"getset": [2]string{
+		"[bucket ...] bucket key value",
+		strings.Join([]string{
+			"Sets the value of the given key and returns the old value in one transaction.",
+			"Returns nil if the key did not exist. If the bucket does not exist it will be created.",
 		}, "\n"),
 	},
 	"help": [2]string{
@@ -40,6 +170,145 @@ var CmdHelp = map[string][2]string{
 			"Shows the help output for the given command.",
 		}, "\n"),
 	},
+	"incr": [2]string{
+		"[bucket ...] bucket key [-format decimal|u64be|u64le]",
+		strings.Join([]string{
+			"Increments the counter stored in the given key by one in a single transaction, and returns the new value.",
+			"The counter is stored as a decimal string by default, or as a big-endian or little-endian uint64 with -format.",
+			"A missing key counts as 0, and the bucket is created if it does not exist.",
+			"Fails if the value is not in the format, or if the counter would overflow or go below 0 as an uint64.",
+		}, "\n"),
+	},
+	"incrby": [2]string{
+		"[bucket ...] bucket key increment [-format decimal|u64be|u64le]",
+		strings.Join([]string{
+			"Like incr, but adds the increment, which can be negative, to the counter.",
+			"The increment is a decimal number, so it is never decoded with the session wide encoding.",
+		}, "\n"),
+	},
+	"ls": [2]string{
+		"[bucket ...]",
+		strings.Join([]string{
+			"Lists the buckets and keys in the specified bucket, or in the current bucket if no bucket is given.",
+			"Bucket names end with '/'.",
+		}, "\n"),
+	},
+	"meta": [2]string{
+		"[bucket ...] name",
+		strings.Join([]string{
+			"Describes the key or the bucket with the given name: its type and path, and for a key, the size of the value.",
+			"The sequence and whether the bucket is inline are about the bucket containing the key,",
+			"or about the bucket itself if the name is a bucket. Fails if there is no such key or bucket.",
+		}, "\n"),
+	},
+	"mget": [2]string{
+		"bucket key [key ...] [-as decoder]",
+		strings.Join([]string{
+			"Returns the values of the keys in the specified bucket as a list, in one transaction.",
+			"An empty string is returned for a key which does not exist.",
+			"The bucket is a single argument: a bucket in the current bucket, or an absolute path",
+			"for a nested bucket, so 'mget /bucket/subbucket k1 k2' reads k1 and k2 in bucket/subbucket.",
+			"The values are decoded like get does.",
+		}, "\n"),
+	},
+	"move": [2]string{
+		"source destination [-overwrite]",
+		strings.Join([]string{
+			"Like copy, but deletes the source afterward in the same transaction.",
+		}, "\n"),
+	},
+	"mset": [2]string{
+		"bucket key value [key value ...]",
+		strings.Join([]string{
+			"Sets the values of the keys in the specified bucket in one transaction, and returns true.",
+			"The bucket is a single argument like mget, and the missing buckets in the path are created.",
+		}, "\n"),
+	},
+	"multi": [2]string{
+		"",
+		strings.Join([]string{
+			"Starts a transaction. The following commands are queued, until exec runs them or discard drops them.",
+		}, "\n"),
+	},
+	"nextseq": [2]string{
+		"[bucket ...] bucket",
+		strings.Join([]string{
+			"Increments the sequence of the specified bucket and returns it, like Bucket.NextSequence.",
+			"If the bucket does not exist it will be created.",
+		}, "\n"),
+	},
+	"output": [2]string{
+		"[text|json|ndjson]",
+		strings.Join([]string{
+			"Sets the output format of commands, and returns the current format.",
+			"In json and ndjson format, each result is wrapped in an object like {\"ok\": true, \"result\": ...},",
+			"or {\"ok\": false, \"result\": null, \"error\": \"...\"} if the command failed.",
+			"The json format is indented, while the ndjson format prints one line per result.",
+			"Strings which are not valid UTF-8 are written as {\"base64\": \"...\"}.",
+		}, "\n"),
+	},
+	"page": [2]string{
+		"id",
+		strings.Join([]string{
+			"Returns the header of the page and what is in it, which is read from the file:",
+			"the fields of a meta page, the keys of a leaf page (bucket names end with '/'),",
+			"the first key of each child page of a branch page, or the free page ids in a freelist page.",
+		}, "\n"),
+	},
+	"pages": [2]string{
+		"[-start id] [-limit N]",
+		strings.Join([]string{
+			"Lists the pages in the database file with their types, item counts and overflow page counts.",
+			"The type is meta, freelist, branch, leaf or free. The overflow pages of a page are not listed.",
+			"-start starts the listing from the given page id, and -limit returns at most N pages.",
+		}, "\n"),
+	},
+	"pwd": [2]string{
+		"",
+		strings.Join([]string{
+			"Returns the path of the current bucket.",
+		}, "\n"),
+	},
+	"range": [2]string{
+		"[bucket ...] bucket [-start key] [-end key] [-prefix prefix] [-limit N] [-reverse] [-cursor key] [-as decoder]",
+		strings.Join([]string{
+			"Like scan, but returns the keys along with their values: [cursor, [key1, value1, key2, value2, ...]].",
+			"The values are decoded like get does.",
+		}, "\n"),
+	},
+	"rename": [2]string{
+		"source name [-overwrite]",
+		strings.Join([]string{
+			"Like move, but the destination is the given name in the same bucket as the source.",
+		}, "\n"),
+	},
+	"restore": [2]string{
+		"file",
+		strings.Join([]string{
+			"Loads the buckets, keys and values from a file written by the dump command in one transaction,",
+			"and returns the number of buckets and keys. Existing buckets are kept, and existing keys are overwritten.",
+		}, "\n"),
+	},
+	"scan": [2]string{
+		"[bucket ...] bucket [-start key] [-end key] [-prefix prefix] [-limit N] [-reverse] [-cursor key]",
+		strings.Join([]string{
+			"Lists the keys in the specified bucket in order with a cursor, and returns [cursor, [key1, key2, ...]].",
+			"Only the keys between -start (inclusive) and -end (exclusive) and starting with -prefix are listed.",
+			"-limit N returns at most N keys, 0 means no limit. -reverse lists the keys in reverse order.",
+			"If the limit is reached, the returned cursor is the next key to list, otherwise it is an empty string.",
+			"Pass it with -cursor to continue the scan. A cursor outside of the range starts from its bound.",
+		}, "\n"),
+	},
+	"search": [2]string{
+		"[bucket ...] pattern [-glob] [-regex] [-keys] [-limit N]",
+		strings.Join([]string{
+			"Searches the values in the specified bucket and all its nested buckets, or in the whole database",
+			"if no bucket is given, and returns the paths of the matched keys like /bucket/subbucket:key.",
+			"The pattern is a substring of the value, or a glob matching the whole value with -glob,",
+			"or a regular expression with -regex. With -keys, the names of keys are matched too.",
+			"The search stops after N matches if -limit is given.",
+		}, "\n"),
+	},
 	"set": [2]string{
 		"[bucket ...] bucket key value",
 		strings.Join([]string{
@@ -47,12 +316,46 @@ var CmdHelp = map[string][2]string{
 			"If the bucket does not exist it will be created.",
 		}, "\n"),
 	},
+	"setnx": [2]string{
+		"[bucket ...] bucket key value",
+		strings.Join([]string{
+			"Sets the value of the given key only if the key does not exist, and returns whether the value is set.",
+			"The check and the write are done in one transaction. If the bucket does not exist it will be created.",
+		}, "\n"),
+	},
+	"setseq": [2]string{
+		"[bucket ...] bucket sequence",
+		strings.Join([]string{
+			"Sets the sequence of the specified bucket, and returns true.",
+			"If the bucket does not exist it will be created.",
+		}, "\n"),
+	},
+	"strlen": [2]string{
+		"[bucket ...] bucket key",
+		strings.Join([]string{
+			"Returns the length of the value of the given key in the specified bucket, or 0 if the key does not exist.",
+			"Fails if the name is a bucket.",
+		}, "\n"),
+	},
+	"type": [2]string{
+		"[bucket ...] name",
+		strings.Join([]string{
+			"Returns 'bucket' if the name is a bucket, 'key' if it is a key, or 'none' if it does not exist.",
+		}, "\n"),
+	},
 	"buckets": [2]string{
 		"[bucket ...] bucket-pattern",
 		strings.Join([]string{
 			"Lists all buckets matching the given glob pattern.",
 		}, "\n"),
 	},
+	"info": [2]string{
+		"",
+		strings.Join([]string{
+			"Returns the page size, the file size, the freelist statistics and both meta pages of the database.",
+			"The meta pages are read from the file, and Valid shows whether the magic, version and checksum are correct.",
+		}, "\n"),
+	},
 	"keys": [2]string{
 		"[bucket ...] bucket key-pattern",
 		strings.Join([]string{
@@ -60,9 +363,20 @@ var CmdHelp = map[string][2]string{
 		}, "\n"),
 	},
 	"keyvalues": [2]string{
-		"[bucket ...] bucket key-pattern",
+		"[bucket ...] bucket key-pattern [-as decoder]",
 		strings.Join([]string{
 			"Lists all keys and their associated values in the specified bucket matching the given glob pattern.",
+			"The values are decoded like get does.",
+		}, "\n"),
+	},
+	"tree": [2]string{
+		"[bucket ...] [-depth N] [-values]",
+		strings.Join([]string{
+			"Shows the nested buckets under the specified bucket, or all buckets if no bucket is given.",
+			"Each bucket shows the number of keys it holds in the 'keys' field,",
+			"and its nested buckets in the 'children' field.",
+			"-depth N limits the levels of nested buckets shown, 0 means no limit.",
+			"-values also shows the keys and their values in 'children', truncated to 32 bytes.",
 		}, "\n"),
 	},
 	"stats": [2]string{
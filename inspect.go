@@ -0,0 +1,191 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	typeBucket = "bucket"
+	typeKey    = "key"
+	typeNone   = "none"
+
+	defaultBigKeysLimit = 10
+)
+
+func init() {
+	cmdOptions["bigkeys"] = optSpec{
+		"-limit": optValue,
+	}
+}
+
+// lookupName returns the bucket or the value with the last name in path.
+// Both of them are nil if there is no such bucket or key.
+func lookupName(tx *bolt.Tx, path []string) (*bolt.Bucket, []byte) {
+	parent, _ := parentOf(tx, path, false)
+	if parent == nil {
+		return nil, nil
+	}
+	return lookupEntry(parent, []byte(path[len(path)-1]))
+}
+
+func strlen(args ...string) (res interface{}, err error) {
+	argsLen := len(args)
+	if argsLen < 2 {
+		return nil, fmt.Errorf("wrong number of arguments for '%s' command", "strlen")
+	}
+	err = view(func(tx *bolt.Tx) error {
+		b, v := lookupName(tx, args)
+		if b != nil {
+			return bolt.ErrIncompatibleValue
+		}
+		res = int64(len(v))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func typeCmd(args ...string) (res interface{}, err error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("wrong number of arguments for '%s' command", "type")
+	}
+	res = typeNone
+	err = view(func(tx *bolt.Tx) error {
+		b, v := lookupName(tx, args)
+		if b != nil {
+			res = typeBucket
+		} else if v != nil {
+			res = typeKey
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func bucketMeta(b *bolt.Bucket) map[string]interface{} {
+	return map[string]interface{}{
+		"sequence": normalizeInt(b.Sequence()),
+		"inline":   b.Root() == 0,
+	}
+}
+
+// meta describes the key or the bucket with the last name in path. For a key, it returns the size of
+// the value and the metadata of the bucket which contains the key. For a bucket, it returns its own metadata.
+func meta(args ...string) (res interface{}, err error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("wrong number of arguments for '%s' command", "meta")
+	}
+	dir := args[:len(args)-1]
+	err = view(func(tx *bolt.Tx) error {
+		b, v := lookupName(tx, args)
+		var info map[string]interface{}
+		switch {
+		case b != nil:
+			info = bucketMeta(b)
+			info["type"] = typeBucket
+			info["path"] = formatPath(args)
+		case v != nil:
+			info = bucketMeta(findBucket(tx, dir))
+			info["type"] = typeKey
+			info["path"] = formatKeyPath(dir, args[len(args)-1])
+			info["size"] = int64(len(v))
+		default:
+			return fmt.Errorf("no such key or bucket: %s", formatPath(args))
+		}
+		res = info
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+type bigKey struct {
+	path string
+	size int
+}
+
+// bigKeyHeap is a min-heap of keys by the size of values, so that the smallest one can be replaced
+type bigKeyHeap []bigKey
+
+func (h bigKeyHeap) Len() int            { return len(h) }
+func (h bigKeyHeap) Less(i, j int) bool  { return h[i].size < h[j].size }
+func (h bigKeyHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *bigKeyHeap) Push(x interface{}) { *h = append(*h, x.(bigKey)) }
+func (h *bigKeyHeap) Pop() interface{} {
+	old := *h
+	k := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return k
+}
+
+// bigkeys finds the keys with the largest values under the bucket, or in the whole database
+// if no bucket is given, and returns them with the sizes of values, the largest first.
+func bigkeys(args ...string) (res interface{}, err error) {
+	opts, args, err := parseOptions("bigkeys", args)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := intOption(opts, "-limit", defaultBigKeysLimit)
+	if err != nil {
+		return nil, err
+	}
+	if limit == 0 {
+		return nil, fmt.Errorf("value of option '%s' should be a positive integer", "-limit")
+	}
+
+	h := &bigKeyHeap{}
+	var walk func(path []string, b *bolt.Bucket) error
+	walk = func(path []string, b *bolt.Bucket) error {
+		return b.ForEach(func(k, v []byte) error {
+			if v == nil {
+				return walk(append(append([]string{}, path...), string(k)), b.Bucket(k))
+			}
+			if h.Len() == limit {
+				if len(v) <= (*h)[0].size {
+					return nil
+				}
+				heap.Pop(h)
+			}
+			heap.Push(h, bigKey{formatKeyPath(path, string(k)), len(v)})
+			return nil
+		})
+	}
+	err = view(func(tx *bolt.Tx) error {
+		if len(args) == 0 {
+			return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+				return walk([]string{string(name)}, b)
+			})
+		}
+		if b := findBucket(tx, args); b != nil {
+			return walk(args, b)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []bigKey(*h)
+	sort.SliceStable(keys, func(i, j int) bool {
+		if keys[i].size != keys[j].size {
+			return keys[i].size > keys[j].size
+		}
+		return keys[i].path < keys[j].path
+	})
+	results := make([]interface{}, len(keys))
+	for i, k := range keys {
+		results[i] = []interface{}{k.path, int64(k.size)}
+	}
+	return results, nil
+}
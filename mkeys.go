@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func init() {
+	cmdOptions["mget"] = optSpec{"-as": optValue}
+}
+
+// bucketPath splits the resolved bucket argument of mget and mset, which should not contain a key.
+func bucketPath(arg string) ([]string, error) {
+	path, hasKey, err := splitPath(arg)
+	if err != nil {
+		return nil, err
+	}
+	if hasKey {
+		return nil, fmt.Errorf("invalid bucket path '%s': it should not contain a key", arg)
+	}
+	return path, nil
+}
+
+// mget returns the values of the keys in a bucket as a list in one transaction.
+// The bucket is the first argument, which is resolved to an absolute path (see absPathCmds).
+// A missing value is an empty string.
+func mget(args ...string) (res interface{}, err error) {
+	opts, args, err := parseOptions("mget", args)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) < 2 {
+		return nil, fmt.Errorf("wrong number of arguments for '%s' command", "mget")
+	}
+	path, err := bucketPath(args[0])
+	if err != nil {
+		return nil, err
+	}
+	var values []interface{}
+	err = view(func(tx *bolt.Tx) error {
+		b := findBucket(tx, path)
+		dec, explicit, err := valueDecoder(opts["-as"], path)
+		if err != nil {
+			return err
+		}
+		values = make([]interface{}, 0, len(args)-1)
+		for _, k := range args[1:] {
+			var v []byte
+			if b != nil {
+				v = b.Get([]byte(k))
+			}
+			if v == nil {
+				values = append(values, "")
+				continue
+			}
+			value, err := decodeValue(dec, explicit, []byte(k), v)
+			if err != nil {
+				return err
+			}
+			values = append(values, value)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// mset sets the pairs of keys and values in a bucket in one transaction, and returns true.
+// The bucket is the first argument like mget, and it is created if it doesn't exist.
+func mset(args ...string) (res interface{}, err error) {
+	if len(args) < 3 || len(args)%2 == 0 {
+		return nil, fmt.Errorf("wrong number of arguments for '%s' command", "mset")
+	}
+	path, err := bucketPath(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(path) == 0 {
+		return nil, bolt.ErrBucketNameRequired
+	}
+	err = update(func(tx *bolt.Tx) error {
+		b, err := createBucket(tx, path)
+		if err != nil {
+			return err
+		}
+		for i := 1; i < len(args); i += 2 {
+			if err = b.Put([]byte(args[i]), []byte(args[i+1])); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return true, nil
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// maxDecodeDepth limits the nesting of decoded values, so that a malformed value can't exhaust the stack
+const maxDecodeDepth = 100
+
+// msgpackTimestamp is the extension type of timestamps
+const msgpackTimestamp = -1
+
+var errTruncated = errors.New("unexpected end of data")
+
+// msgpackReader decodes a MessagePack value to the structure shown by Decoded.
+// Map keys which are not strings are converted to strings, like what is done when msgpack is converted to json.
+type msgpackReader struct {
+	data []byte
+	pos  int
+}
+
+func decodeMsgpack(b []byte) (interface{}, error) {
+	r := &msgpackReader{data: b}
+	v, err := r.value(0)
+	if err != nil {
+		return nil, err
+	}
+	if r.pos != len(r.data) {
+		return nil, fmt.Errorf("extra data after the value at offset %d", r.pos)
+	}
+	return v, nil
+}
+
+func (r *msgpackReader) next(n int) ([]byte, error) {
+	if n < 0 || len(r.data)-r.pos < n {
+		return nil, errTruncated
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+// uint reads a big-endian unsigned integer in size bytes
+func (r *msgpackReader) uint(size int) (uint64, error) {
+	b, err := r.next(size)
+	if err != nil {
+		return 0, err
+	}
+	var n uint64
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+	return n, nil
+}
+
+func (r *msgpackReader) int(size int) (int64, error) {
+	n, err := r.uint(size)
+	if err != nil {
+		return 0, err
+	}
+	shift := uint(64 - size*8)
+	return int64(n<<shift) >> shift, nil
+}
+
+func (r *msgpackReader) value(depth int) (interface{}, error) {
+	if depth > maxDecodeDepth {
+		return nil, errors.New("too deeply nested")
+	}
+	b, err := r.next(1)
+	if err != nil {
+		return nil, err
+	}
+	c := b[0]
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xf0 == 0x80:
+		return r.mapValue(int(c&0x0f), depth)
+	case c&0xf0 == 0x90:
+		return r.array(int(c&0x0f), depth)
+	case c&0xe0 == 0xa0:
+		return r.str(int(c & 0x1f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := r.uint(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		data, err := r.next(int(n))
+		if err != nil {
+			return nil, err
+		}
+		return append(dumpBytes{}, data...), nil
+	case 0xc7, 0xc8, 0xc9:
+		n, err := r.uint(1 << (c - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return r.ext(int(n))
+	case 0xca:
+		n, err := r.uint(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(uint32(n))), nil
+	case 0xcb:
+		n, err := r.uint(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(n), nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := r.uint(1 << (c - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		return normalizeInt(n), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		return r.int(1 << (c - 0xd0))
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return r.ext(1 << (c - 0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := r.uint(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return r.str(int(n))
+	case 0xdc, 0xdd:
+		n, err := r.uint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return r.array(int(n), depth)
+	case 0xde, 0xdf:
+		n, err := r.uint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return r.mapValue(int(n), depth)
+	}
+	return nil, fmt.Errorf("invalid type 0x%02x at offset %d", c, r.pos-1)
+}
+
+func (r *msgpackReader) str(n int) (interface{}, error) {
+	b, err := r.next(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (r *msgpackReader) array(n int, depth int) (interface{}, error) {
+	// each element takes one byte at least
+	if n > len(r.data)-r.pos {
+		return nil, errTruncated
+	}
+	a := make([]interface{}, n)
+	for i := range a {
+		v, err := r.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		a[i] = v
+	}
+	return a, nil
+}
+
+func (r *msgpackReader) mapValue(n int, depth int) (interface{}, error) {
+	if n > len(r.data)-r.pos {
+		return nil, errTruncated
+	}
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := r.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		v, err := r.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		switch k := k.(type) {
+		case string:
+			m[k] = v
+		case dumpBytes:
+			m[string(k)] = v
+		default:
+			m[fmt.Sprint(k)] = v
+		}
+	}
+	return m, nil
+}
+
+// ext decodes the extension types. Timestamps are converted to RFC 3339 times,
+// and the other types are kept with their type numbers.
+func (r *msgpackReader) ext(n int) (interface{}, error) {
+	typ, err := r.int(1)
+	if err != nil {
+		return nil, err
+	}
+	data, err := r.next(n)
+	if err != nil {
+		return nil, err
+	}
+	if typ == msgpackTimestamp {
+		var t time.Time
+		switch n {
+		case 4:
+			t = time.Unix(int64(binary.BigEndian.Uint32(data)), 0)
+		case 8:
+			v := binary.BigEndian.Uint64(data)
+			t = time.Unix(int64(v&(1<<34-1)), int64(v>>34))
+		case 12:
+			t = time.Unix(int64(binary.BigEndian.Uint64(data[4:])), int64(binary.BigEndian.Uint32(data)))
+		default:
+			return nil, fmt.Errorf("invalid timestamp with %d bytes", n)
+		}
+		return t.UTC().Format(time.RFC3339Nano), nil
+	}
+	return map[string]interface{}{
+		"type": typ,
+		"data": append(dumpBytes{}, data...),
+	}, nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	outputText   = "text"
+	outputJSON   = "json"
+	outputNDJSON = "ndjson"
+)
+
+var (
+	// OutputFormat is the format of command results in the command line.
+	OutputFormat = outputText
+
+	outputFormats = []string{outputText, outputJSON, outputNDJSON}
+)
+
+// jsonEnvelope is what a command result looks like in json output.
+// For example, `get bucket key` outputs {"ok":true,"result":"value"},
+// and a failed command outputs {"ok":false,"result":null,"error":"the reason"}.
+// A command queued after multi outputs {"ok":true,"result":null,"status":"QUEUED"}.
+type jsonEnvelope struct {
+	OK     bool        `json:"ok"`
+	Result interface{} `json:"result"`
+	Status string      `json:"status,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+func setOutputFormat(format string) error {
+	format = strings.ToLower(format)
+	for _, f := range outputFormats {
+		if f == format {
+			OutputFormat = f
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown output format '%s', available formats: %s",
+		format, strings.Join(outputFormats, ", "))
+}
+
+// formatJSON formats the result of a command in json (indented) or ndjson (one line).
+func formatJSON(res interface{}, err error) string {
+	envelope := jsonEnvelope{OK: err == nil}
+	if err != nil {
+		envelope.Error = err.Error()
+	} else {
+		switch r := res.(type) {
+		case HelpOutput:
+			envelope.Result = string(r)
+		case Status:
+			envelope.Status = string(r)
+		default:
+			res, err := jsonResult(res)
+			if err != nil {
+				return formatJSON(nil, err)
+			}
+			envelope.Result = res
+		}
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if OutputFormat == outputJSON {
+		enc.SetIndent("", "  ")
+	}
+	if err := enc.Encode(envelope); err != nil {
+		return formatJSON(nil, fmt.Errorf("failed to encode result: %v", err))
+	}
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+// jsonResult replaces the strings which are not valid UTF-8 in the result with dumpBytes,
+// which are written as {"base64": "..."} like in dump files. Otherwise encoding/json would
+// replace the invalid bytes with U+FFFD. Such a string can't be the name of a field, so it is an error.
+func jsonResult(res interface{}) (interface{}, error) {
+	switch res := res.(type) {
+	case string:
+		if !utf8.ValidString(res) {
+			return dumpBytes(res), nil
+		}
+	case []string:
+		converted := make([]interface{}, len(res))
+		for i, s := range res {
+			converted[i], _ = jsonResult(s)
+		}
+		return converted, nil
+	case []interface{}:
+		converted := make([]interface{}, len(res))
+		for i, v := range res {
+			var err error
+			if converted[i], err = jsonResult(v); err != nil {
+				return nil, err
+			}
+		}
+		return converted, nil
+	case map[string]interface{}:
+		converted := make(map[string]interface{}, len(res))
+		for k, v := range res {
+			if !utf8.ValidString(k) {
+				return nil, fmt.Errorf("%q is not valid UTF-8, use another encoding like hex to output it in json", k)
+			}
+			var err error
+			if converted[k], err = jsonResult(v); err != nil {
+				return nil, err
+			}
+		}
+		return converted, nil
+	}
+	return res, nil
+}
+
+func output(args ...string) (res interface{}, err error) {
+	if len(args) > 1 {
+		return nil, fmt.Errorf("wrong number of arguments for '%s' command", "output")
+	}
+	if len(args) == 1 {
+		err = setOutputFormat(args[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return OutputFormat, nil
+}
@@ -0,0 +1,448 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strconv"
+	"unsafe"
+)
+
+// The layout of pages in the database file, see page.go and db.go in bbolt.
+// The integers are stored in the native byte order.
+const (
+	pageHeaderSize = 16
+	// both branch and leaf elements have 16 bytes
+	pageElementSize = 16
+	// metaChecksumOffset is where the checksum is in the meta, the checksum covers the fields before it
+	metaChecksumOffset = 56
+
+	branchPageFlag   = 0x01
+	leafPageFlag     = 0x02
+	metaPageFlag     = 0x04
+	freelistPageFlag = 0x10
+	bucketLeafFlag   = 0x01
+
+	metaMagic   = 0xED0CDAED
+	metaVersion = 2
+	// noFreelist is the freelist page id when the freelist is not synced to the file
+	noFreelist = ^uint64(0)
+)
+
+var nativeEndian binary.ByteOrder = binary.LittleEndian
+
+func init() {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 0 {
+		nativeEndian = binary.BigEndian
+	}
+
+	cmdOptions["pages"] = optSpec{
+		"-start": optValue,
+		"-limit": optValue,
+	}
+}
+
+type pageHeader struct {
+	id       uint64
+	flags    uint16
+	count    int
+	overflow int
+}
+
+func (h *pageHeader) typ() string {
+	switch {
+	case h.flags&branchPageFlag != 0:
+		return "branch"
+	case h.flags&leafPageFlag != 0:
+		return "leaf"
+	case h.flags&metaPageFlag != 0:
+		return "meta"
+	case h.flags&freelistPageFlag != 0:
+		return "freelist"
+	default:
+		return fmt.Sprintf("unknown<%02x>", h.flags)
+	}
+}
+
+type metaInfo struct {
+	magic    uint32
+	version  uint32
+	pageSize uint32
+	flags    uint32
+	root     uint64
+	sequence uint64
+	freelist uint64
+	pageN    uint64
+	txid     uint64
+	checksum uint64
+	valid    bool
+}
+
+func parseMeta(buf []byte) *metaInfo {
+	data := buf[pageHeaderSize:]
+	m := &metaInfo{
+		magic:    nativeEndian.Uint32(data[0:]),
+		version:  nativeEndian.Uint32(data[4:]),
+		pageSize: nativeEndian.Uint32(data[8:]),
+		flags:    nativeEndian.Uint32(data[12:]),
+		root:     nativeEndian.Uint64(data[16:]),
+		sequence: nativeEndian.Uint64(data[24:]),
+		freelist: nativeEndian.Uint64(data[32:]),
+		pageN:    nativeEndian.Uint64(data[40:]),
+		txid:     nativeEndian.Uint64(data[48:]),
+		checksum: nativeEndian.Uint64(data[metaChecksumOffset:]),
+	}
+	h := fnv.New64a()
+	h.Write(data[:metaChecksumOffset])
+	m.valid = m.magic == metaMagic && m.version == metaVersion && h.Sum64() == m.checksum
+	return m
+}
+
+func (m *metaInfo) result() map[string]interface{} {
+	return map[string]interface{}{
+		"Magic":    fmt.Sprintf("0x%08x", m.magic),
+		"Version":  int64(m.version),
+		"PageSize": int64(m.pageSize),
+		"Flags":    int64(m.flags),
+		"Root":     int64(m.root),
+		"Sequence": int64(m.sequence),
+		// -1 if the freelist is not synced
+		"Freelist": int64(m.freelist),
+		"PageN":    int64(m.pageN),
+		"TxID":     int64(m.txid),
+		"Checksum": fmt.Sprintf("0x%016x", m.checksum),
+		"Valid":    m.valid,
+	}
+}
+
+// pageFile reads the pages of the database from the file, so that the pages can be
+// inspected even if they are not loaded by bolt, like the freelist in read-only mode.
+type pageFile struct {
+	f        *os.File
+	pageSize int
+}
+
+func openPageFile() (*pageFile, error) {
+	f, err := os.Open(DbPath)
+	if err != nil {
+		return nil, err
+	}
+	return &pageFile{f: f, pageSize: DB.Info().PageSize}, nil
+}
+
+func (pf *pageFile) Close() error {
+	return pf.f.Close()
+}
+
+// read reads n pages starting from given page
+func (pf *pageFile) read(id uint64, n int) ([]byte, error) {
+	buf := make([]byte, pf.pageSize*n)
+	if _, err := pf.f.ReadAt(buf, int64(id)*int64(pf.pageSize)); err != nil {
+		return nil, fmt.Errorf("failed to read page %d: %v", id, err)
+	}
+	return buf, nil
+}
+
+func (pf *pageFile) header(id uint64) (*pageHeader, error) {
+	buf := make([]byte, pageHeaderSize)
+	if _, err := pf.f.ReadAt(buf, int64(id)*int64(pf.pageSize)); err != nil {
+		return nil, fmt.Errorf("failed to read page %d: %v", id, err)
+	}
+	return &pageHeader{
+		id:       nativeEndian.Uint64(buf[0:]),
+		flags:    nativeEndian.Uint16(buf[8:]),
+		count:    int(nativeEndian.Uint16(buf[10:])),
+		overflow: int(nativeEndian.Uint32(buf[12:])),
+	}, nil
+}
+
+// metas returns both meta pages
+func (pf *pageFile) metas() ([]*metaInfo, error) {
+	metas := make([]*metaInfo, 2)
+	for i := range metas {
+		buf, err := pf.read(uint64(i), 1)
+		if err != nil {
+			return nil, err
+		}
+		metas[i] = parseMeta(buf)
+	}
+	return metas, nil
+}
+
+// currentMeta returns the valid meta page with the latest transaction, like what bolt uses
+func (pf *pageFile) currentMeta() (*metaInfo, error) {
+	metas, err := pf.metas()
+	if err != nil {
+		return nil, err
+	}
+	var cur *metaInfo
+	for _, m := range metas {
+		if m.valid && (cur == nil || m.txid > cur.txid) {
+			cur = m
+		}
+	}
+	if cur == nil {
+		return nil, fmt.Errorf("both meta pages are invalid")
+	}
+	return cur, nil
+}
+
+// readPage reads the page with its overflow pages, and checks the page id in the header.
+// The overflow count is checked against the database and the file before the pages are read,
+// so that a corrupted header can't make it allocate too much memory.
+func (pf *pageFile) readPage(h *pageHeader, id uint64, m *metaInfo) ([]byte, error) {
+	if h.id != id {
+		return nil, fmt.Errorf("page %d is corrupted: the header says it is page %d", id, h.id)
+	}
+	end := id + uint64(h.overflow)
+	if end >= m.pageN {
+		return nil, fmt.Errorf("page %d is corrupted: its %d overflow pages exceed the %d pages of the database",
+			id, h.overflow, m.pageN)
+	}
+	fi, err := pf.f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if (end+1)*uint64(pf.pageSize) > uint64(fi.Size()) {
+		return nil, fmt.Errorf("page %d is corrupted: its %d overflow pages exceed the size of the file",
+			id, h.overflow)
+	}
+	return pf.read(id, h.overflow+1)
+}
+
+// freePages returns the ids of pages in the freelist
+func (pf *pageFile) freePages(m *metaInfo) ([]uint64, error) {
+	if m.freelist == noFreelist {
+		return nil, nil
+	}
+	h, err := pf.header(m.freelist)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := pf.readPage(h, m.freelist, m)
+	if err != nil {
+		return nil, err
+	}
+	return parseFreelist(h, buf)
+}
+
+func parseFreelist(h *pageHeader, buf []byte) ([]uint64, error) {
+	count, start := uint64(h.count), pageHeaderSize
+	// the count overflows, so the real count is stored in the first element
+	if count == 0xFFFF {
+		count = nativeEndian.Uint64(buf[pageHeaderSize:])
+		start += 8
+	}
+	if count > uint64(len(buf)-start)/8 {
+		return nil, fmt.Errorf("page %d is corrupted: %d free pages don't fit in the page", h.id, count)
+	}
+	ids := make([]uint64, count)
+	for i := range ids {
+		ids[i] = nativeEndian.Uint64(buf[start+i*8:])
+	}
+	return ids, nil
+}
+
+// pageElement returns the key of the element at given index in a branch or leaf page.
+// The key is stored at pos bytes after the element.
+func pageElement(h *pageHeader, buf []byte, i int, pos, ksize uint32) ([]byte, error) {
+	start := pageHeaderSize + i*pageElementSize + int(pos)
+	end := start + int(ksize)
+	if end > len(buf) || end < start {
+		return nil, fmt.Errorf("page %d is corrupted: key of element %d is out of the page", h.id, i)
+	}
+	return buf[start:end], nil
+}
+
+// leafKeys returns the keys in a leaf page, and the keys of buckets end with the path separator like ls
+func leafKeys(h *pageHeader, buf []byte) ([]string, error) {
+	if pageHeaderSize+h.count*pageElementSize > len(buf) {
+		return nil, fmt.Errorf("page %d is corrupted: %d elements don't fit in the page", h.id, h.count)
+	}
+	keys := make([]string, h.count)
+	for i := range keys {
+		elem := buf[pageHeaderSize+i*pageElementSize:]
+		k, err := pageElement(h, buf, i, nativeEndian.Uint32(elem[4:]), nativeEndian.Uint32(elem[8:]))
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = encodeOutput(string(k))
+		if nativeEndian.Uint32(elem[0:])&bucketLeafFlag != 0 {
+			keys[i] += PathSeparator
+		}
+	}
+	return keys, nil
+}
+
+// branchChildren returns the first key of each child page in a branch page
+func branchChildren(h *pageHeader, buf []byte) (map[string]interface{}, error) {
+	if pageHeaderSize+h.count*pageElementSize > len(buf) {
+		return nil, fmt.Errorf("page %d is corrupted: %d elements don't fit in the page", h.id, h.count)
+	}
+	children := map[string]interface{}{}
+	for i := 0; i < h.count; i++ {
+		elem := buf[pageHeaderSize+i*pageElementSize:]
+		k, err := pageElement(h, buf, i, nativeEndian.Uint32(elem[0:]), nativeEndian.Uint32(elem[4:]))
+		if err != nil {
+			return nil, err
+		}
+		children[encodeOutput(string(k))] = int64(nativeEndian.Uint64(elem[8:]))
+	}
+	return children, nil
+}
+
+func pageResult(h *pageHeader, id uint64, typ string) map[string]interface{} {
+	return map[string]interface{}{
+		"ID":            int64(id),
+		"Type":          typ,
+		"Count":         int64(h.count),
+		"OverflowCount": int64(h.overflow),
+	}
+}
+
+func info(args ...string) (res interface{}, err error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("wrong number of arguments for '%s' command", "info")
+	}
+	pf, err := openPageFile()
+	if err != nil {
+		return nil, err
+	}
+	defer pf.Close()
+	metas, err := pf.metas()
+	if err != nil {
+		return nil, err
+	}
+	fi, err := pf.f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	stat := DB.Stats()
+	return map[string]interface{}{
+		"Path":          DbPath,
+		"PageSize":      int64(pf.pageSize),
+		"FileSize":      fi.Size(),
+		"FreePageN":     int64(stat.FreePageN),
+		"PendingPageN":  int64(stat.PendingPageN),
+		"FreeAlloc":     int64(stat.FreeAlloc),
+		"FreelistInuse": int64(stat.FreelistInuse),
+		"Meta0":         metas[0].result(),
+		"Meta1":         metas[1].result(),
+	}, nil
+}
+
+func pages(args ...string) (res interface{}, err error) {
+	opts, args, err := parseOptions("pages", args)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) != 0 {
+		return nil, fmt.Errorf("wrong number of arguments for '%s' command", "pages")
+	}
+	start, err := intOption(opts, "-start", 0)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := intOption(opts, "-limit", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	pf, err := openPageFile()
+	if err != nil {
+		return nil, err
+	}
+	defer pf.Close()
+	m, err := pf.currentMeta()
+	if err != nil {
+		return nil, err
+	}
+	ids, err := pf.freePages(m)
+	if err != nil {
+		return nil, err
+	}
+	free := map[uint64]bool{}
+	for _, id := range ids {
+		free[id] = true
+	}
+
+	list := []interface{}{}
+	for id := uint64(start); id < m.pageN && (limit == 0 || len(list) < limit); {
+		h, err := pf.header(id)
+		if err != nil {
+			return nil, err
+		}
+		typ := h.typ()
+		if free[id] {
+			typ = "free"
+		}
+		list = append(list, pageResult(h, id, typ))
+		id += uint64(h.overflow) + 1
+	}
+	return list, nil
+}
+
+func page(args ...string) (res interface{}, err error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("wrong number of arguments for '%s' command", "page")
+	}
+	id, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page id '%s'", args[0])
+	}
+
+	pf, err := openPageFile()
+	if err != nil {
+		return nil, err
+	}
+	defer pf.Close()
+	m, err := pf.currentMeta()
+	if err != nil {
+		return nil, err
+	}
+	if id >= m.pageN {
+		return nil, fmt.Errorf("page %d is out of range, the database has %d pages", id, m.pageN)
+	}
+	ids, err := pf.freePages(m)
+	if err != nil {
+		return nil, err
+	}
+	h, err := pf.header(id)
+	if err != nil {
+		return nil, err
+	}
+	for _, freeID := range ids {
+		if freeID == id {
+			// the content of a free page is meaningless
+			return pageResult(h, id, "free"), nil
+		}
+	}
+
+	info := pageResult(h, id, h.typ())
+	buf, err := pf.readPage(h, id, m)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case h.flags&metaPageFlag != 0:
+		info["Meta"] = parseMeta(buf).result()
+	case h.flags&leafPageFlag != 0:
+		info["Keys"], err = leafKeys(h, buf)
+	case h.flags&branchPageFlag != 0:
+		info["Children"], err = branchChildren(h, buf)
+	case h.flags&freelistPageFlag != 0:
+		var free []uint64
+		free, err = parseFreelist(h, buf)
+		list := make([]interface{}, len(free))
+		for i, id := range free {
+			list[i] = int64(id)
+		}
+		info["FreePages"] = list
+	}
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
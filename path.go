@@ -0,0 +1,463 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// keySeparator separates the key from the buckets in a path like /bucket/subbucket:key
+const keySeparator = ':'
+
+var (
+	// PathSeparator separates the bucket names in a path like /bucket/subbucket:key
+	PathSeparator = "/"
+
+	// CurBucket is the path of the current bucket, which is empty for the root.
+	// The arguments of commands in pathCmds are relative to it.
+	CurBucket = []string{}
+
+	// pathCmds are the commands which take a bucket path as the leading arguments
+	pathCmds = map[string]bool{
+		"bigkeys":     true,
+		"buckets":     true,
+		"bucketstats": true,
+		"cas":         true,
+		"decr":        true,
+		"del":         true,
+		"delglob":     true,
+		"exists":      true,
+		"get":         true,
+		"getset":      true,
+		"incr":        true,
+		"incrby":      true,
+		"keys":        true,
+		"keyvalues":   true,
+		"ls":          true,
+		"meta":        true,
+		"mget":        true,
+		"mset":        true,
+		"nextseq":     true,
+		"range":       true,
+		"scan":        true,
+		"search":      true,
+		"set":         true,
+		"setnx":       true,
+		"setseq":      true,
+		"strlen":      true,
+		"tree":        true,
+		"type":        true,
+	}
+
+	// patternCmds are the commands in pathCmds whose last argument is a pattern or a search term.
+	// When it is the only argument, it is never taken as a path, so `delglob /*` still matches
+	// the top level buckets whose names start with "/".
+	patternCmds = map[string]bool{
+		"buckets": true,
+		"delglob": true,
+		"search":  true,
+	}
+
+	// absPathCmds are the commands whose leading positional arguments are paths, with the number of them.
+	// Each path is a name in the current bucket or an absolute path like /bucket/subbucket:key.
+	// resolveArgs resolves it to an absolute path which is not encoded, and the command splits it
+	// with splitPath. It suits the commands which take several paths, or a path followed by
+	// a varying number of arguments.
+	absPathCmds = map[string]int{
+		"copy":   2,
+		"mget":   1,
+		"move":   2,
+		"mset":   1,
+		"rename": 1,
+	}
+
+	// selfEncodedCmds are the commands which encode their output by themselves
+	selfEncodedCmds = map[string]bool{
+		"bigkeys":     true,
+		"bucketstats": true,
+		"ls":          true,
+		"meta":        true,
+		"search":      true,
+		"tree":        true,
+		"type":        true,
+	}
+)
+
+// resolveArgs resolves the bucket path in the arguments of given command, and decodes the arguments
+// with the session wide Encoding if decode is true.
+// The first argument which is not an option can be an absolute path like /bucket/subbucket:key,
+// which is expanded to the positional arguments "bucket", "subbucket" and "key".
+// Otherwise the arguments are relative to the current bucket.
+func resolveArgs(cmd string, args []string, decode bool) ([]string, error) {
+	if n, ok := absPathCmds[cmd]; ok {
+		return resolveAbsPaths(cmd, args, n, decode)
+	}
+	absolute := false
+	if pathCmds[cmd] {
+		i := pathArgIndex(cmd, args)
+		if i >= 0 && isPath(args[i]) {
+			parts, _, err := splitPath(args[i])
+			if err != nil {
+				return nil, err
+			}
+			expanded := append(append([]string{}, args[:i]...), parts...)
+			args = append(expanded, args[i+1:]...)
+			absolute = true
+		} else if i >= 0 && !decode {
+			if name, ok := rawPathName(args[i]); ok {
+				args = append([]string{}, args...)
+				args[i] = name
+			}
+		}
+	}
+	if decode {
+		var err error
+		args, err = decodeArgs(cmd, args)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if absolute || !pathCmds[cmd] || len(CurBucket) == 0 {
+		return args, nil
+	}
+	// Don't let a command which modifies the database default to the current bucket,
+	// so that `del` without arguments won't delete the current bucket.
+	if len(args) == 0 && writeCmds[cmd] {
+		return args, nil
+	}
+	return append(append([]string{}, CurBucket...), args...), nil
+}
+
+// resolveAbsPaths is resolveArgs for the commands in absPathCmds, which take n paths.
+func resolveAbsPaths(cmd string, args []string, n int, decode bool) ([]string, error) {
+	resolved := append([]string{}, args...)
+	paths := map[int]string{}
+	for _, i := range positionalArgs(cmd, args) {
+		if len(paths) == n {
+			break
+		}
+		path, err := resolveAbsPath(args[i], decode)
+		if err != nil {
+			return nil, err
+		}
+		paths[i] = path
+		// the path is already decoded
+		resolved[i] = ""
+	}
+	if decode {
+		var err error
+		if resolved, err = decodeArgs(cmd, resolved); err != nil {
+			return nil, err
+		}
+	}
+	for i, path := range paths {
+		resolved[i] = path
+	}
+	return resolved, nil
+}
+
+// resolveAbsPath resolves a name in the current bucket or an absolute path to an absolute path,
+// and decodes the names in it with the session wide Encoding if decode is true.
+func resolveAbsPath(arg string, decode bool) (string, error) {
+	if name, ok := rawPathName(arg); ok && !decode {
+		return joinPath(append(append([]string{}, CurBucket...), name)), nil
+	}
+	if !isPath(arg) {
+		name := arg
+		if decode {
+			var err error
+			if name, err = decodeArg(arg); err != nil {
+				return "", err
+			}
+		}
+		return joinPath(append(append([]string{}, CurBucket...), name)), nil
+	}
+	parts, hasKey, err := splitPath(arg)
+	if err != nil {
+		return "", err
+	}
+	if decode {
+		for i, part := range parts {
+			if parts[i], err = decodeArg(part); err != nil {
+				return "", err
+			}
+		}
+	}
+	if hasKey {
+		return joinKeyPath(parts[:len(parts)-1], parts[len(parts)-1]), nil
+	}
+	return joinPath(parts), nil
+}
+
+// positionalArgs returns the indexes of the arguments which are neither options nor their values.
+func positionalArgs(cmd string, args []string) []int {
+	spec := cmdOptions[cmd]
+	indexes := []int{}
+	for i := 0; i < len(args); i++ {
+		if spec != nil && args[i] == "--" {
+			spec = nil
+			continue
+		}
+		if kind, ok := spec[args[i]]; ok {
+			if kind != optFlag {
+				i++
+			}
+			continue
+		}
+		indexes = append(indexes, i)
+	}
+	return indexes
+}
+
+// pathArgIndex returns the index of the first argument which is not an option, or -1 if there is none
+// or the only one is the pattern of a command in patternCmds.
+func pathArgIndex(cmd string, args []string) int {
+	indexes := positionalArgs(cmd, args)
+	if len(indexes) == 0 || (len(indexes) == 1 && patternCmds[cmd]) {
+		return -1
+	}
+	return indexes[0]
+}
+
+func setPathSeparator(sep string) error {
+	if sep == "" || strings.ContainsAny(sep, string(keySeparator)+"\\") {
+		return fmt.Errorf("invalid path separator '%s', it should be non-empty and contain neither '%c' nor '\\'", sep, keySeparator)
+	}
+	PathSeparator = sep
+	return nil
+}
+
+func isPath(arg string) bool {
+	return strings.HasPrefix(arg, PathSeparator)
+}
+
+// rawPathName returns the name in an argument like raw:/name, and reports whether it is such an argument.
+// The prefix "raw:" keeps a name which starts with the path separator from being taken as a path.
+// decodeArg removes the prefix in the command line, while the arguments from Lua are not decoded,
+// so resolveArgs removes it for them.
+func rawPathName(arg string) (string, bool) {
+	name := strings.TrimPrefix(arg, "raw:")
+	return name, len(name) != len(arg) && isPath(name)
+}
+
+// splitPath splits a path like /bucket/subbucket:key into the bucket names and the optional key,
+// and reports whether the key is given. Empty bucket names are ignored, so / is the root.
+// A backslash escapes the next character, so that the separators can be used in names.
+func splitPath(path string) ([]string, bool, error) {
+	parts := []string{}
+	var cur strings.Builder
+	inKey := false
+	for i := len(PathSeparator); i < len(path); i++ {
+		switch {
+		case path[i] == '\\':
+			if i+1 >= len(path) {
+				return nil, false, fmt.Errorf("invalid path '%s': nothing to escape at the end", path)
+			}
+			i++
+			cur.WriteByte(path[i])
+		case inKey:
+			cur.WriteByte(path[i])
+		case strings.HasPrefix(path[i:], PathSeparator):
+			if cur.Len() > 0 {
+				parts = append(parts, cur.String())
+				cur.Reset()
+			}
+			i += len(PathSeparator) - 1
+		case path[i] == keySeparator:
+			if cur.Len() > 0 {
+				parts = append(parts, cur.String())
+				cur.Reset()
+			}
+			inKey = true
+		default:
+			cur.WriteByte(path[i])
+		}
+	}
+	if inKey || cur.Len() > 0 {
+		parts = append(parts, cur.String())
+	}
+	return parts, inKey, nil
+}
+
+// decodePath is like splitPath, and also decodes the names with the session wide Encoding.
+func decodePath(path string) ([]string, bool, error) {
+	parts, hasKey, err := splitPath(path)
+	if err != nil {
+		return nil, false, err
+	}
+	for i, part := range parts {
+		if parts[i], err = decodeArg(part); err != nil {
+			return nil, false, err
+		}
+	}
+	return parts, hasKey, nil
+}
+
+// resolvePath returns the full path of an argument, which is either an absolute path
+// or the name of a bucket or a key in the current bucket.
+func resolvePath(arg string) ([]string, error) {
+	if isPath(arg) {
+		path, _, err := decodePath(arg)
+		return path, err
+	}
+	name, err := decodeArg(arg)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]string{}, CurBucket...), name), nil
+}
+
+// escapePathName escapes the separators in a bucket name, so that it can be used in a path
+func escapePathName(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '\\' || name[i] == keySeparator || strings.HasPrefix(name[i:], PathSeparator) {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(name[i])
+	}
+	return b.String()
+}
+
+// joinPath joins the bucket names to a path like /bucket/subbucket, which splitPath splits back.
+// Unlike formatPath, the names are not encoded.
+func joinPath(path []string) string {
+	if len(path) == 0 {
+		return PathSeparator
+	}
+	var b strings.Builder
+	for _, name := range path {
+		b.WriteString(PathSeparator)
+		b.WriteString(escapePathName(name))
+	}
+	return b.String()
+}
+
+// formatPath formats the bucket path like /bucket/subbucket
+func formatPath(path []string) string {
+	encoded := make([]string, len(path))
+	for i, name := range path {
+		encoded[i] = encodeOutput(name)
+	}
+	return joinPath(encoded)
+}
+
+// joinKeyPath joins the bucket names and the key to a path like /bucket/subbucket:key, without encoding.
+func joinKeyPath(bucket []string, key string) string {
+	return joinPath(bucket) + string(keySeparator) + escapePathName(key)
+}
+
+// formatKeyPath formats the path of a key like /bucket/subbucket:key
+func formatKeyPath(bucket []string, key string) string {
+	return formatPath(bucket) + string(keySeparator) + escapePathName(encodeOutput(key))
+}
+
+// findBucket returns the bucket in given path, or nil if the path is empty or any bucket in it doesn't exist.
+func findBucket(tx *bolt.Tx, path []string) *bolt.Bucket {
+	if len(path) == 0 {
+		return nil
+	}
+	b := tx.Bucket([]byte(path[0]))
+	for i := 1; b != nil && i < len(path); i++ {
+		b = b.Bucket([]byte(path[i]))
+	}
+	return b
+}
+
+// createBucket returns the bucket in given path, and creates the missing buckets along the path.
+func createBucket(tx *bolt.Tx, path []string) (*bolt.Bucket, error) {
+	var parent bucketCreator = tx
+	var b *bolt.Bucket
+	for _, name := range path {
+		var err error
+		b, err = parent.CreateBucketIfNotExists([]byte(name))
+		if err != nil {
+			return nil, err
+		}
+		parent = b
+	}
+	return b, nil
+}
+
+func cd(args ...string) (res interface{}, err error) {
+	path := append([]string{}, CurBucket...)
+	if len(args) == 0 {
+		path = []string{}
+	}
+	for _, arg := range args {
+		if isPath(arg) {
+			parts, hasKey, err := decodePath(arg)
+			if err != nil {
+				return nil, err
+			}
+			if hasKey {
+				return nil, fmt.Errorf("'%s' is not a bucket path", arg)
+			}
+			path = parts
+			continue
+		}
+		switch arg {
+		case "..":
+			if len(path) > 0 {
+				path = path[:len(path)-1]
+			}
+		case ".":
+		default:
+			name, err := decodeArg(arg)
+			if err != nil {
+				return nil, err
+			}
+			path = append(path, name)
+		}
+	}
+	if len(path) > 0 {
+		err = view(func(tx *bolt.Tx) error {
+			if findBucket(tx, path) == nil {
+				return errors.New("no such bucket: " + formatPath(path))
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	CurBucket = path
+	return formatPath(CurBucket), nil
+}
+
+func pwd(args ...string) (res interface{}, err error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("wrong number of arguments for '%s' command", "pwd")
+	}
+	return formatPath(CurBucket), nil
+}
+
+func ls(args ...string) (res interface{}, err error) {
+	entries := []string{}
+	err = view(func(tx *bolt.Tx) error {
+		if len(args) == 0 {
+			return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+				entries = append(entries, encodeOutput(string(name))+PathSeparator)
+				return nil
+			})
+		}
+		b := findBucket(tx, args)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			entry := encodeOutput(string(k))
+			if b.Bucket(k) != nil {
+				entry += PathSeparator
+			}
+			entries = append(entries, entry)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
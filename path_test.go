@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitPath(t *testing.T) {
+	parts, hasKey, err := splitPath("/bucket/subbucket:key")
+	assert.Nil(t, err)
+	assert.True(t, hasKey)
+	assert.Equal(t, []string{"bucket", "subbucket", "key"}, parts)
+
+	parts, hasKey, err = splitPath("/bucket//subbucket/")
+	assert.Nil(t, err)
+	assert.False(t, hasKey)
+	assert.Equal(t, []string{"bucket", "subbucket"}, parts)
+
+	parts, hasKey, err = splitPath("/")
+	assert.Nil(t, err)
+	assert.False(t, hasKey)
+	assert.Equal(t, []string{}, parts)
+
+	// separators are literal in the key
+	parts, _, err = splitPath(`/a\/b\:c\\:k/e:y`)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{`a/b:c\`, "k/e:y"}, parts)
+
+	parts, hasKey, err = splitPath("/bucket:")
+	assert.Nil(t, err)
+	assert.True(t, hasKey)
+	assert.Equal(t, []string{"bucket", ""}, parts)
+
+	_, _, err = splitPath(`/bucket\`)
+	assert.NotNil(t, err)
+
+	assert.Equal(t, `a\/b\:c\\`, escapePathName(`a/b:c\`))
+}
+
+func TestPathSeparator(t *testing.T) {
+	defer setPathSeparator("/")
+	for _, sep := range []string{"", ":", `\`, "a:"} {
+		assert.NotNil(t, setPathSeparator(sep), sep)
+	}
+	assert.Nil(t, setPathSeparator("."))
+	parts, _, err := splitPath(`.a/b.c\.d:key`)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a/b", "c.d", "key"}, parts)
+	assert.Equal(t, ".a/b.c\\.d", formatPath([]string{"a/b", "c.d"}))
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// decodeProtobuf decodes a protobuf message without its schema, like `protoc --decode_raw`.
+// The fields are keyed by their numbers, and the repeated fields become lists.
+// Length-delimited fields are shown as strings if they are printable,
+// as nested messages if they can be parsed so, or as bytes otherwise.
+func decodeProtobuf(b []byte) (interface{}, error) {
+	return parseProtoMessage(b, 0)
+}
+
+func parseProtoMessage(b []byte, depth int) (map[string]interface{}, error) {
+	if depth > maxDecodeDepth {
+		return nil, errors.New("too deeply nested")
+	}
+	fields := map[string]interface{}{}
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errors.New("invalid field key")
+		}
+		b = b[n:]
+		num, wireType := key>>3, key&7
+		if num == 0 || num > math.MaxInt32 {
+			return nil, fmt.Errorf("invalid field number %d", num)
+		}
+
+		var v interface{}
+		switch wireType {
+		case wireVarint:
+			x, n := binary.Uvarint(b)
+			if n <= 0 {
+				return nil, fmt.Errorf("invalid varint in field %d", num)
+			}
+			b = b[n:]
+			v = normalizeInt(x)
+		case wireFixed64:
+			if len(b) < 8 {
+				return nil, errTruncated
+			}
+			v = normalizeInt(binary.LittleEndian.Uint64(b))
+			b = b[8:]
+		case wireFixed32:
+			if len(b) < 4 {
+				return nil, errTruncated
+			}
+			v = int64(binary.LittleEndian.Uint32(b))
+			b = b[4:]
+		case wireBytes:
+			size, n := binary.Uvarint(b)
+			if n <= 0 || size > uint64(len(b)-n) {
+				return nil, errTruncated
+			}
+			data := b[n : n+int(size)]
+			b = b[n+int(size):]
+			v = protoBytes(data, depth)
+		default:
+			return nil, fmt.Errorf("unsupported wire type %d in field %d", wireType, num)
+		}
+
+		name := strconv.FormatUint(num, 10)
+		switch prev := fields[name].(type) {
+		case nil:
+			fields[name] = v
+		case []interface{}:
+			fields[name] = append(prev, v)
+		default:
+			fields[name] = []interface{}{prev, v}
+		}
+	}
+	return fields, nil
+}
+
+func protoBytes(data []byte, depth int) interface{} {
+	if isPrintable(data) {
+		return string(data)
+	}
+	if msg, err := parseProtoMessage(data, depth+1); err == nil {
+		return msg
+	}
+	return append(dumpBytes{}, data...)
+}
+
+func isPrintable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
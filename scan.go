@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// scanOptions are the options shared by scan and range
+var scanOptions = optSpec{
+	"-start":   optData,
+	"-end":     optData,
+	"-prefix":  optData,
+	"-cursor":  optData,
+	"-limit":   optValue,
+	"-reverse": optFlag,
+}
+
+func init() {
+	cmdOptions["scan"] = scanOptions
+	rangeOptions := optSpec{"-as": optValue}
+	for name, kind := range scanOptions {
+		rangeOptions[name] = kind
+	}
+	cmdOptions["range"] = rangeOptions
+}
+
+type scanRange struct {
+	start   []byte
+	end     []byte
+	prefix  []byte
+	cursor  []byte
+	limit   int
+	reverse bool
+	// the name of the decoder, only used by range
+	as string
+
+	// the inclusive lower bound and the exclusive upper bound computed from start, end and prefix.
+	// nil means no bound.
+	lowerBound []byte
+	upperBound []byte
+}
+
+func parseScanRange(cmd string, args []string) (*scanRange, []string, error) {
+	opts, args, err := parseOptions(cmd, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	r := &scanRange{}
+	r.limit, err = intOption(opts, "-limit", 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, r.reverse = opts["-reverse"]
+	r.as = opts["-as"]
+	if s, ok := opts["-start"]; ok {
+		r.start = []byte(s)
+	}
+	if s, ok := opts["-end"]; ok {
+		r.end = []byte(s)
+	}
+	if s, ok := opts["-prefix"]; ok {
+		r.prefix = []byte(s)
+	}
+	if s, ok := opts["-cursor"]; ok && s != "" {
+		r.cursor = []byte(s)
+	}
+	r.lowerBound = r.lower()
+	r.upperBound = r.upper()
+	return r, args, nil
+}
+
+// prefixEnd returns the smallest key which is greater than all keys with the prefix,
+// or nil if there is no such key.
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte{}, prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+func (r *scanRange) lower() []byte {
+	if bytes.Compare(r.prefix, r.start) > 0 {
+		return r.prefix
+	}
+	return r.start
+}
+
+func (r *scanRange) upper() []byte {
+	end := r.end
+	if len(r.prefix) > 0 {
+		pend := prefixEnd(r.prefix)
+		if pend != nil && (end == nil || bytes.Compare(pend, end) < 0) {
+			end = pend
+		}
+	}
+	return end
+}
+
+func (r *scanRange) inRange(k []byte) bool {
+	if r.lowerBound != nil && bytes.Compare(k, r.lowerBound) < 0 {
+		return false
+	}
+	if r.upperBound != nil && bytes.Compare(k, r.upperBound) >= 0 {
+		return false
+	}
+	return bytes.HasPrefix(k, r.prefix)
+}
+
+// first positions the cursor to the first key to visit
+func (r *scanRange) first(c *bolt.Cursor) ([]byte, []byte) {
+	// a cursor outside of the bounds starts from the bound
+	if !r.reverse {
+		from := r.lowerBound
+		if r.cursor != nil && bytes.Compare(r.cursor, from) > 0 {
+			from = r.cursor
+		}
+		if from == nil {
+			return c.First()
+		}
+		return c.Seek(from)
+	}
+
+	// the cursor is inclusive, while the upper bound is exclusive
+	from, inclusive := r.upperBound, false
+	if r.cursor != nil && (from == nil || bytes.Compare(r.cursor, from) < 0) {
+		from, inclusive = r.cursor, true
+	}
+	if from == nil {
+		return c.Last()
+	}
+	k, v := c.Seek(from)
+	if k == nil {
+		return c.Last()
+	}
+	if cmp := bytes.Compare(k, from); cmp > 0 || (cmp == 0 && !inclusive) {
+		return c.Prev()
+	}
+	return k, v
+}
+
+// scanBucket calls fn with each key and value in the range, and returns the next cursor.
+// The next cursor is nil if all keys in the range are visited.
+// Sub-buckets are skipped.
+func scanBucket(b *bolt.Bucket, r *scanRange, fn func(k, v []byte)) []byte {
+	c := b.Cursor()
+	next := c.Next
+	if r.reverse {
+		next = c.Prev
+	}
+	count := 0
+	for k, v := r.first(c); k != nil; k, v = next() {
+		if !r.inRange(k) {
+			break
+		}
+		if v == nil {
+			// sub-bucket
+			continue
+		}
+		if r.limit > 0 && count == r.limit {
+			return append([]byte{}, k...)
+		}
+		fn(k, v)
+		count++
+	}
+	return nil
+}
+
+// scanCmd implements scan and range, which share the same arguments.
+func scanCmd(cmd string, withValues bool, args []string) (res interface{}, err error) {
+	r, args, err := parseScanRange(cmd, args)
+	if err != nil {
+		return nil, err
+	}
+	argsLen := len(args)
+	if argsLen < 1 {
+		return nil, fmt.Errorf("wrong number of arguments for '%s' command", cmd)
+	}
+	dec, explicit, err := valueDecoder(r.as, args)
+	if err != nil {
+		return nil, err
+	}
+	items := []interface{}{}
+	var cursor []byte
+	err = view(func(tx *bolt.Tx) error {
+		b := findBucket(tx, args)
+		if b == nil {
+			return nil
+		}
+		var err error
+		cursor = scanBucket(b, r, func(k, v []byte) {
+			if err != nil {
+				return
+			}
+			items = append(items, string(k))
+			if withValues {
+				var value interface{}
+				value, err = decodeValue(dec, explicit, k, v)
+				items = append(items, value)
+			}
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{string(cursor), items}, nil
+}
+
+func scan(args ...string) (res interface{}, err error) {
+	return scanCmd("scan", false, args)
+}
+
+func rangeCmd(args ...string) (res interface{}, err error) {
+	return scanCmd("range", true, args)
+}
@@ -6,10 +6,19 @@ import (
 	"github.com/Shopify/go-lua"
 )
 
+// apiMetaTable is the name of the meta table shared by the bolt table and the tx tables
+const apiMetaTable = "boltcli.api"
+
 var (
 	vm *lua.State
 
 	curCmd string
+
+	// txFuncs are the functions in the API other than the commands
+	txFuncs = map[string]lua.Function{
+		"update": luaUpdate,
+		"view":   luaView,
+	}
 )
 
 func init() {
@@ -21,7 +30,7 @@ func init() {
 func injectAPI(L *lua.State) {
 	L.CreateTable(0, 1)
 
-	L.CreateTable(0, 1)
+	lua.NewMetaTable(L, apiMetaTable)
 	L.PushGoFunction(dispatchCmd)
 	L.SetField(-2, "__index")
 	L.SetMetaTable(-2)
@@ -45,6 +54,10 @@ func dispatchCmd(L *lua.State) int {
 			L.PushGoFunction(execCmdInLuaScript)
 			return 1
 		}
+		if f, ok := txFuncs[s]; ok {
+			L.PushGoFunction(f)
+			return 1
+		}
 	}
 	// it is equal to return nil
 	return 0
@@ -62,13 +75,8 @@ func pushMap(L *lua.State, res map[string]interface{}) {
 	L.CreateTable(0, len(res))
 	for k, v := range res {
 		L.PushString(k)
-		switch v := v.(type) {
-		case int64:
-			L.PushInteger(int(v))
-		case string:
-			L.PushString(v)
-		case map[string]interface{}:
-			pushMap(L, v)
+		if !pushResult(L, v) {
+			L.PushNil()
 		}
 		L.RawSet(-3)
 	}
@@ -94,30 +102,145 @@ func execCmdInLuaScript(L *lua.State) int {
 	}
 	// we have checked the existence of 'curCmd' before
 	f, _ := CmdMap[curCmd]
+	err := checkWritable(curCmd)
+	if err != nil {
+		L.PushNil()
+		L.PushString(err.Error())
+		return 2
+	}
+	args, err = resolveArgs(curCmd, args, false)
+	if err != nil {
+		L.PushNil()
+		L.PushString(err.Error())
+		return 2
+	}
+	if queueCmd(curCmd, args) {
+		L.PushString(string(statusQueued))
+		return 1
+	}
 	res, err := f(args...)
 	if err != nil {
 		L.PushNil()
 		L.PushString(err.Error())
 		return 2
 	}
+	if !pushResult(L, res) {
+		L.PushFString("The type of result returns from command '%s' with args %v is unsupported", curCmd, args)
+		L.Error()
+	}
+	return 1
+}
+
+// pushResult pushes the result of a command, and returns false if the type of result is unsupported.
+func pushResult(L *lua.State, res interface{}) bool {
 	switch res := res.(type) {
+	case nil:
+		L.PushNil()
 	case bool:
 		L.PushBoolean(res)
 	case []byte:
 		L.PushString(string(res))
 	case string:
 		L.PushString(res)
+	case HelpOutput:
+		L.PushString(string(res))
+	case Status:
+		L.PushString(string(res))
+	case execResults:
+		return pushResult(L, res.results)
 	case []string:
 		pushList(L, res)
+	case []interface{}:
+		L.CreateTable(len(res), 0)
+		for i, v := range res {
+			if !pushResult(L, v) {
+				L.Pop(1)
+				return false
+			}
+			L.RawSetInt(-2, i+1)
+		}
 	case map[string]interface{}:
 		pushMap(L, res)
 	case int:
 		L.PushInteger(res)
+	case int64:
+		L.PushInteger(int(res))
+	case uint64:
+		L.PushNumber(float64(res))
+	case Decoded:
+		pushDecoded(L, res.Value)
 	default:
-		L.PushFString("The type of result returns from command '%s' with args %v is unsupported", curCmd, args)
+		return false
+	}
+	return true
+}
+
+// pushDecoded pushes the structure converted by a decoder. Null becomes nil.
+func pushDecoded(L *lua.State, v interface{}) {
+	switch v := v.(type) {
+	case bool:
+		L.PushBoolean(v)
+	case int64:
+		L.PushInteger(int(v))
+	case uint64:
+		L.PushNumber(float64(v))
+	case float64:
+		L.PushNumber(v)
+	case string:
+		L.PushString(v)
+	case dumpBytes:
+		L.PushString(string(v))
+	case []interface{}:
+		L.CreateTable(len(v), 0)
+		for i, e := range v {
+			pushDecoded(L, e)
+			L.RawSetInt(-2, i+1)
+		}
+	case map[string]interface{}:
+		L.CreateTable(0, len(v))
+		for k, e := range v {
+			L.PushString(k)
+			pushDecoded(L, e)
+			L.RawSet(-3)
+		}
+	default:
+		L.PushNil()
+	}
+}
+
+// runLuaTx calls the function given as the first argument with a tx table,
+// and runs all commands called by the function in a single transaction.
+// The transaction is rolled back if the function raises an error, and the error is raised again.
+// Otherwise it returns what the function returns.
+func runLuaTx(L *lua.State, writable bool) int {
+	lua.CheckType(L, 1, lua.TypeFunction)
+	L.SetTop(1)
+	raised := false
+	err := runInTx(writable, func() error {
+		L.PushValue(1)
+		// tx provides the same commands as the global bolt table
+		L.NewTable()
+		lua.SetMetaTableNamed(L, apiMetaTable)
+		err := L.ProtectedCall(1, lua.MultipleReturns, 0)
+		raised = err != nil
+		return err
+	})
+	if err != nil {
+		// the error raised by the function is already on the top of stack
+		if !raised {
+			L.PushString(err.Error())
+		}
 		L.Error()
 	}
-	return 1
+	return L.Top() - 1
+}
+
+func luaUpdate(L *lua.State) int {
+	return runLuaTx(L, true)
+}
+
+func luaView(L *lua.State) int {
+	return runLuaTx(L, false)
 }
 
 // StartScript evals given script file
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/gobwas/glob"
+	bolt "go.etcd.io/bbolt"
+)
+
+// errSearchLimit stops the search once the limit of matches is reached
+var errSearchLimit = errors.New("the limit of matches is reached")
+
+func init() {
+	cmdOptions["search"] = optSpec{
+		"-glob":  optFlag,
+		"-regex": optFlag,
+		"-keys":  optFlag,
+		"-limit": optValue,
+	}
+}
+
+// compileMatcher compiles the pattern to a function matching the values.
+// The pattern is a substring of the value by default, or a glob or a regular expression.
+func compileMatcher(pattern string, opts map[string]string) (func([]byte) bool, error) {
+	_, isGlob := opts["-glob"]
+	_, isRegex := opts["-regex"]
+	switch {
+	case isGlob && isRegex:
+		return nil, errors.New("-glob and -regex can't be used together")
+	case isGlob:
+		g, err := glob.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		return func(b []byte) bool { return g.Match(string(b)) }, nil
+	case isRegex:
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		return re.Match, nil
+	default:
+		sub := []byte(pattern)
+		return func(b []byte) bool { return bytes.Contains(b, sub) }, nil
+	}
+}
+
+// search finds the keys whose values match the pattern in the bucket and all its nested buckets,
+// or in the whole database if no bucket is given, and returns their paths like /bucket/subbucket:key.
+// With -keys, the keys whose names match the pattern are found too.
+func search(args ...string) (res interface{}, err error) {
+	opts, args, err := parseOptions("search", args)
+	if err != nil {
+		return nil, err
+	}
+	argsLen := len(args)
+	if argsLen < 1 {
+		return nil, fmt.Errorf("wrong number of arguments for '%s' command", "search")
+	}
+	match, err := compileMatcher(args[argsLen-1], opts)
+	if err != nil {
+		return nil, err
+	}
+	_, withKeys := opts["-keys"]
+	limit, err := intOption(opts, "-limit", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	hits := []string{}
+	var walk func(path []string, b *bolt.Bucket) error
+	walk = func(path []string, b *bolt.Bucket) error {
+		return b.ForEach(func(k, v []byte) error {
+			if v == nil {
+				return walk(append(append([]string{}, path...), string(k)), b.Bucket(k))
+			}
+			if match(v) || (withKeys && match(k)) {
+				hits = append(hits, formatKeyPath(path, string(k)))
+				if len(hits) == limit {
+					return errSearchLimit
+				}
+			}
+			return nil
+		})
+	}
+	err = view(func(tx *bolt.Tx) error {
+		var err error
+		if argsLen == 1 {
+			err = tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+				return walk([]string{string(name)}, b)
+			})
+		} else if b := findBucket(tx, args[:argsLen-1]); b != nil {
+			err = walk(args[:argsLen-1], b)
+		}
+		if err == errSearchLimit {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hits, nil
+}
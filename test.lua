@@ -23,6 +23,12 @@ assert(#keys == 1)
 assert(keys[1] == "key")
 local keyvalues = bolt.keyvalues("bucket", "*")
 assert(keyvalues["key"] == "1")
+local scan = bolt.scan("bucket", "-prefix", "k", "-limit", 1)
+assert(scan[1] == "")
+assert(scan[2][1] == "key")
+local tree = bolt.tree("-values")
+assert(tree["bucket"]["keys"] == 1)
+assert(tree["bucket"]["children"]["key"] == "1")
 -- Note that it will return empty table instead of nil for buckets/keys/keyvalus
 assert(next(bolt.buckets("non_exist")) == nil)
 assert(next(bolt.keyvalues("bucket", "non_exist")) == nil)
@@ -44,3 +50,109 @@ for k, v in pairs(stats) do
 end
 assert(stats["FreeAlloc"] > 0)
 assert(stats["TxStats"]["Write"] > 0)
+
+-- transactions
+assert(bolt.update(function(tx)
+    assert(tx.set("tx_bucket", "key", "value"))
+    assert(tx.get("tx_bucket", "key") == "value")
+    return true
+end))
+local ok, err = pcall(bolt.update, function(tx)
+    tx.set("tx_bucket", "key", "new value")
+    tx.set("tx_bucket", "key2", "value2")
+    error("rollback")
+end)
+assert(not ok)
+assert(string.find(err, "rollback"))
+local value, exists = bolt.view(function(tx)
+    return tx.get("tx_bucket", "key"), tx.exists("tx_bucket", "key2")
+end)
+assert(value == "value")
+assert(not exists)
+local res, err = bolt.view(function(tx)
+    return tx.set("tx_bucket", "key", "new value")
+end)
+assert(res == nil)
+assert(err == "can't modify the database in a read-only transaction")
+res, err = bolt.view(function(tx)
+    return tx.delglob("tx_bucket", "*")
+end)
+assert(res == nil)
+assert(err == "can't modify the database in a read-only transaction")
+ok, err = pcall(bolt.view, function()
+    bolt.update(function() end)
+end)
+assert(not ok)
+assert(err == "transactions can not be nested")
+assert(bolt.del("tx_bucket"))
+
+-- bucket path
+assert(bolt.set("/path/sub:key", "value"))
+assert(bolt.get("path", "sub", "key") == "value")
+assert(bolt.get("/path/sub:key") == "value")
+
+-- decoders
+assert(bolt.set("decode", "json", '{"list": [1, "two"], "nested": {"ok": true}}'))
+local value = bolt.get("-as", "json", "decode", "json")
+assert(value.list[1] == 1 and value.list[2] == "two")
+assert(value.nested.ok == true)
+assert(bolt.decoder("/decode", "json") == "json")
+assert(bolt.get("/decode:json").nested.ok == true)
+assert(bolt.decoder("/decode", "none") == "none")
+assert(type(bolt.get("/decode:json")) == "string")
+local res, err = bolt.get("-as", "msgpack", "decode", "json")
+assert(res == nil and string.find(err, "can't decode the value of key 'json'"))
+assert(bolt.del("decode"))
+
+-- counters
+assert(bolt.incr("counter", "n") == 1)
+assert(bolt.incrby("counter", "n", 41) == 42)
+assert(bolt.decr("counter", "n") == 41)
+assert(bolt.nextseq("counter") == 1)
+assert(bolt.setseq("counter", 10))
+assert(bolt.nextseq("counter") == 11)
+assert(bolt.del("counter"))
+
+-- conditional writes
+assert(bolt.setnx("cond", "key", "value"))
+assert(not bolt.setnx("cond", "key", "other"))
+assert(not bolt.cas("cond", "key", "other", "new"))
+assert(bolt.cas("cond", "key", "value", "new"))
+assert(bolt.getset("cond", "key", "newer") == "new")
+assert(bolt.getset("cond", "missing", "value") == nil)
+assert(bolt.get("cond", "key") == "newer")
+assert(bolt.del("cond"))
+
+-- a bucket whose name starts with the path separator
+assert(bolt.set("raw:/odd", "key", "value"))
+assert(bolt.get("/\\/odd:key") == "value")
+assert(bolt.mget("raw:/odd", "key")[1] == "value")
+assert(bolt.del("raw:/odd"))
+assert(not bolt.exists("/\\/odd"))
+
+-- copy takes the arguments as they are, like the other commands
+assert(bolt.set("copy", "0xab", "value"))
+assert(bolt.copy("/copy:0xab", "/copy:0xcd"))
+assert(bolt.get("copy", "0xcd") == "value")
+assert(bolt.del("copy"))
+
+-- multiple keys
+assert(bolt.mset("mkeys", "k1", "v1", "k2", "v2"))
+local values = bolt.mget("mkeys", "k1", "k2", "k3")
+assert(#values == 3 and values[1] == "v1" and values[2] == "v2" and values[3] == "")
+assert(bolt.del("mkeys"))
+
+-- inspection
+assert(bolt.set("inspect", "key", "value"))
+assert(bolt.strlen("inspect", "key") == 5)
+assert(bolt.type("inspect") == "bucket")
+local info = bolt.meta("inspect", "key")
+assert(info.type == "key" and info.size == 5 and info.inline)
+assert(bolt.bigkeys("inspect")[1][2] == 5)
+assert(bolt.del("inspect"))
+
+-- search
+assert(bolt.set("search", "sub", "key", "needle in a haystack"))
+local hits = bolt.search("search", "needle")
+assert(#hits == 1 and hits[1] == "/search/sub:key")
+assert(bolt.del("search"))
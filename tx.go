@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// curTx is the transaction shared by commands running inside multi/exec,
+	// or inside bolt.update/bolt.view in Lua. It is nil otherwise.
+	curTx *bolt.Tx
+	// txQueue holds the commands queued after multi. It is nil if multi is not called.
+	txQueue [][]string
+
+	// txCtrlCmds are the commands which control the queue, so they are never queued
+	txCtrlCmds = map[string]bool{
+		"discard": true,
+		"exec":    true,
+		"multi":   true,
+	}
+
+	errNestedTx = errors.New("transactions can not be nested")
+)
+
+// Status is the result of a command which reports a state instead of data,
+// so that it can be told apart from the data and the help text in json output.
+type Status string
+
+// statusQueued is the result of a command queued after multi
+const statusQueued Status = "QUEUED"
+
+// execResults are the results of the commands run by exec. The names of the commands are kept,
+// so that each result can be encoded like the result returned by the command itself.
+type execResults struct {
+	cmds    []string
+	results []interface{}
+}
+
+func init() {
+	// exec runs the commands in CmdMap, so it can't be put into CmdMap statically
+	CmdMap["exec"] = exec
+}
+
+// view runs fn in the current transaction if there is one, otherwise in a new read-only transaction.
+func view(fn func(*bolt.Tx) error) error {
+	if curTx != nil {
+		return fn(curTx)
+	}
+	return DB.View(fn)
+}
+
+// update runs fn in the current transaction if there is one, otherwise in a new read-write transaction.
+func update(fn func(*bolt.Tx) error) error {
+	if curTx != nil {
+		if !curTx.Writable() {
+			return errors.New("can't modify the database in a read-only transaction")
+		}
+		return fn(curTx)
+	}
+	return DB.Update(fn)
+}
+
+// runInTx starts a transaction which is shared by all commands run by fn.
+// The transaction is rolled back if fn returns an error.
+func runInTx(writable bool, fn func() error) error {
+	if curTx != nil {
+		return errNestedTx
+	}
+	txFn := DB.View
+	if writable {
+		txFn = DB.Update
+	}
+	return txFn(func(tx *bolt.Tx) error {
+		curTx = tx
+		defer func() { curTx = nil }()
+		return fn()
+	})
+}
+
+// queueCmd queues the command if multi is called, and reports whether it is queued.
+func queueCmd(name string, args []string) bool {
+	if txQueue == nil || txCtrlCmds[name] {
+		return false
+	}
+	txQueue = append(txQueue, append([]string{name}, args...))
+	return true
+}
+
+func multi(args ...string) (res interface{}, err error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("wrong number of arguments for '%s' command", "multi")
+	}
+	if txQueue != nil {
+		return nil, errors.New("multi calls can not be nested")
+	}
+	if curTx != nil {
+		return nil, errNestedTx
+	}
+	txQueue = [][]string{}
+	return true, nil
+}
+
+func discard(args ...string) (res interface{}, err error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("wrong number of arguments for '%s' command", "discard")
+	}
+	if txQueue == nil {
+		return nil, errors.New("discard without multi")
+	}
+	txQueue = nil
+	return true, nil
+}
+
+func exec(args ...string) (res interface{}, err error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("wrong number of arguments for '%s' command", "exec")
+	}
+	if txQueue == nil {
+		return nil, errors.New("exec without multi")
+	}
+	queue := txQueue
+	txQueue = nil
+
+	// only start a read-write transaction when it is required,
+	// so that exec also works in read-only mode.
+	writable := false
+	for _, c := range queue {
+		if writeCmds[c[0]] {
+			writable = true
+		}
+	}
+	results := execResults{cmds: []string{}, results: []interface{}{}}
+	err = runInTx(writable, func() error {
+		for i, c := range queue {
+			f, _ := CmdMap[c[0]]
+			res, err := f(c[1:]...)
+			if err != nil {
+				return fmt.Errorf("command %d (%s) failed, transaction is rolled back: %v", i+1, c[0], err)
+			}
+			results.cmds = append(results.cmds, c[0])
+			results.results = append(results.results, res)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}